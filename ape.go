@@ -0,0 +1,154 @@
+// 'ape.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "strings"
+)
+
+
+// See:  http://wiki.hydrogenaud.io/index.php?title=APEv2_specification
+type apeitem struct {
+    key string
+    flags uint32
+    value []byte
+}
+
+
+type ape struct {
+    version int
+    items []*apeitem
+}
+
+
+const apeflagheader = 0x80000000
+const apeflagisheader = 0x20000000
+
+const apeitemtypemask = 0x00000006
+const apeitemtypebinary = 0x00000002
+
+
+// Ape cover art items are named in the same order as id3v2 picture types.
+var apecoverartkeys = []string {
+    "Cover Art (Other)",
+    "Cover Art (Icon)",
+    "Cover Art (Other Icon)",
+    "Cover Art (Front)",
+    "Cover Art (Back)",
+    "Cover Art (Leaflet)",
+    "Cover Art (Media)",
+    "Cover Art (Lead Artist)",
+    "Cover Art (Artist)",
+    "Cover Art (Conductor)",
+    "Cover Art (Band)",
+    "Cover Art (Composer)",
+    "Cover Art (Lyricist)",
+    "Cover Art (Recording Location)",
+    "Cover Art (During Recording)",
+    "Cover Art (During Performance)",
+    "Cover Art (Video Capture)",
+    "Cover Art (Fish)",
+    "Cover Art (Illustration)",
+    "Cover Art (Band Logotype)",
+    "Cover Art (Publisher Logotype)",
+}
+
+
+// Bytes are either header, items and footer, or items and footer.
+func newapefrombytes(bytes []byte) (a *ape, err error) {
+
+    // Header and footer are:
+    // 0..7:     'APETAGEX'.
+    // 8..11:    version.
+    // 12..15:   size, including items and footer.
+    // 16..19:   item count.
+    // 20..23:   flags.
+    // 24..31:   reserved.
+
+    if len(bytes) < 32 {
+        return nil, fmt.Errorf("Unable to find ape header.")
+    }
+
+    var header []byte
+    var items []byte
+    if string(bytes[0:8]) == "APETAGEX" &&
+       binary.LittleEndian.Uint32(bytes[20:24]) & apeflagisheader != 0 {
+        if len(bytes) < 64 {
+            return nil, fmt.Errorf("Unable to find ape footer.")
+        }
+        header = bytes[0:32]
+        items = bytes[32:len(bytes) - 32]
+    } else if string(bytes[len(bytes) - 32:len(bytes) - 24]) == "APETAGEX" {
+        header = bytes[len(bytes) - 32:]
+        items = bytes[0:len(bytes) - 32]
+    } else {
+        return nil, fmt.Errorf("Unable to find ape header.")
+    }
+
+    a = new(ape)
+    a.version = int(binary.LittleEndian.Uint32(header[8:12]))
+    count := int(binary.LittleEndian.Uint32(header[16:20]))
+
+    // Item is:
+    // 0..3:     value size.
+    // 4..7:     flags.
+    // 8..:      key, terminated.
+    // ..:       value.
+
+    for j := 0; j < count; j++ {
+        if len(items) < 9 {
+            return nil, fmt.Errorf("Unable to parse ape item.")
+        }
+
+        item := new(apeitem)
+        n := int(binary.LittleEndian.Uint32(items[0:4]))
+        item.flags = binary.LittleEndian.Uint32(items[4:8])
+
+        k := 0
+        for 8 + k < len(items) && items[8 + k] != 0 {
+            k++
+        }
+        if 8 + k + 1 + n > len(items) {
+            return nil, fmt.Errorf("Unable to parse ape item.")
+        }
+        item.key = string(items[8:8 + k])
+        item.value = items[8 + k + 1:8 + k + 1 + n]
+
+        a.items = append(a.items, item)
+        items = items[8 + k + 1 + n:]
+    }
+
+    return a, nil
+}
+
+
+// Binary cover art items are the filename, terminated, followed by the image.
+func (a *ape) coverart() (apics []*id3v2apic) {
+    for _, item := range a.items {
+        if item.flags & apeitemtypemask != apeitemtypebinary {
+            continue
+        }
+
+        for picturetype, key := range apecoverartkeys {
+            if !strings.EqualFold(item.key, key) {
+                continue
+            }
+
+            data := item.value
+            if k := bytes.IndexByte(data, 0); k != -1 {
+                data = data[k + 1:]
+            }
+
+            apics = append(apics, &id3v2apic{ mimetype: imagemimetype(data),
+                                              picturetype: byte(picturetype),
+                                              data: data })
+        }
+    }
+    return apics
+}
@@ -0,0 +1,120 @@
+// 'ape_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "encoding/binary"
+    "testing"
+)
+
+
+// Returns an ape tag holding items, with a header if header.
+func testapeitems(items []*apeitem, header bool) []byte {
+    body := []byte{}
+    for _, item := range items {
+        bytes8 := make([]byte, 8)
+        binary.LittleEndian.PutUint32(bytes8[0:4], uint32(len(item.value)))
+        binary.LittleEndian.PutUint32(bytes8[4:8], item.flags)
+        body = append(body, bytes8...)
+        body = append(body, item.key...)
+        body = append(body, 0)
+        body = append(body, item.value...)
+    }
+
+    headerfooter := func(flags uint32) []byte {
+        bytes := make([]byte, 32)
+        copy(bytes[0:8], "APETAGEX")
+        binary.LittleEndian.PutUint32(bytes[8:12], 2000)
+        binary.LittleEndian.PutUint32(bytes[12:16], uint32(len(body) + 32))
+        binary.LittleEndian.PutUint32(bytes[16:20], uint32(len(items)))
+        binary.LittleEndian.PutUint32(bytes[20:24], flags)
+        return bytes
+    }
+
+    if !header {
+        return append(body, headerfooter(0)...)
+    }
+    bytes := headerfooter(apeflagheader | apeflagisheader)
+    bytes = append(bytes, body...)
+    return append(bytes, headerfooter(apeflagheader)...)
+}
+
+
+func Test_newapefrombytes(t *testing.T) {
+    items := []*apeitem{
+        &apeitem{ key: "Title", value: []byte("Title") },
+        &apeitem{ key: "Cover Art (Back)",
+                  flags: apeitemtypebinary,
+                  value: []byte("back.png\x00\x89PNG\r\n\x1a\n") },
+    }
+
+    for _, header := range []bool{ true, false } {
+        a, err := newapefrombytes(testapeitems(items, header))
+        if ! (err == nil &&
+              a.version == 2000 &&
+              len(a.items) == 2 &&
+              a.items[0].key == "Title" &&
+              string(a.items[0].value) == "Title" &&
+              a.items[1].flags == apeitemtypebinary) {
+            t.Errorf("Test_newapefrombytes:  failed")
+            return
+        }
+
+        apics := a.coverart()
+        if ! (len(apics) == 1 &&
+              apics[0].picturetype == 4 &&
+              apics[0].mimetype == "image/png" &&
+              bytes.Equal(apics[0].data, []byte("\x89PNG\r\n\x1a\n"))) {
+            t.Errorf("Test_newapefrombytes:  failed")
+            return
+        }
+    }
+
+    // Truncated items.
+    bytes := testapeitems(items, true)
+    binary.LittleEndian.PutUint32(bytes[16:20], 3)
+    if _, err := newapefrombytes(bytes); err == nil {
+        t.Errorf("Test_newapefrombytes:  failed")
+        return
+    }
+
+    // A header without a footer, e.g. from a corrupt size of 0.
+    header := testapeitems(nil, true)[0:32]
+    binary.LittleEndian.PutUint32(header[12:16], 0)
+    if _, err := newapefrombytes(header); err == nil {
+        t.Errorf("Test_newapefrombytes:  failed")
+        return
+    }
+}
+
+
+func Test_parseape(t *testing.T) {
+    ape := testapeitems([]*apeitem{ &apeitem{ key: "Title",
+                                              value: []byte("Title") } },
+                        true)
+    stream := append(append(testmp3frame(), ape...), testmp3frame()...)
+
+    h := newmp3adoracollecthandler()
+    if _, err := newmp3adora(h).parse(bytes.NewReader(stream));
+       ! (err == nil &&
+          len(h.apes) == 1 &&
+          h.apes[0].items[0].key == "Title") {
+        t.Errorf("Test_parseape:  failed")
+        return
+    }
+
+    // A header without a footer is skipped.
+    header := testapeitems(nil, true)[0:32]
+    binary.LittleEndian.PutUint32(header[12:16], 0)
+    stream = append(append(testmp3frame(), header...), testmp3frame()...)
+    h = newmp3adoracollecthandler()
+    if _, err := newmp3adora(h).parse(bytes.NewReader(stream));
+       ! (err == nil && len(h.apes) == 0) {
+        t.Errorf("Test_parseape:  failed")
+        return
+    }
+}
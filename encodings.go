@@ -7,36 +7,130 @@ package main
 
 import (
     "fmt"
+    "io"
+    "sort"
     "strings"
 
     "golang.org/x/text/encoding"
     "golang.org/x/text/encoding/charmap"
+    "golang.org/x/text/encoding/ianaindex"
+    "golang.org/x/text/encoding/japanese"
+    "golang.org/x/text/encoding/korean"
+    "golang.org/x/text/encoding/simplifiedchinese"
+    "golang.org/x/text/encoding/traditionalchinese"
 )
 
 
+// Note x/text has no ISO-8859-11, but windows-874 only adds characters in
+// the 0x80 to 0x9f range which ISO-8859-11 leaves unused.
 var charmaps = map[string]encoding.Encoding {
-    "iso8859-1":  charmap.ISO8859_1,
-    "iso8859-2":  charmap.ISO8859_2,
-    "iso8859-3":  charmap.ISO8859_3,
-    "iso8859-4":  charmap.ISO8859_4,
-    "iso8859-5":  charmap.ISO8859_5,
-    "iso8859-6":  charmap.ISO8859_6,
-    "iso8859-7":  charmap.ISO8859_7,
-    "iso8859-8":  charmap.ISO8859_8,
-    "iso8859-10": charmap.ISO8859_10,
-    "iso8859-13": charmap.ISO8859_13,
-    "iso8859-14": charmap.ISO8859_14,
-    "iso8859-15": charmap.ISO8859_15,
-    "iso8859-16": charmap.ISO8859_16,
+    "iso8859-1":    charmap.ISO8859_1,
+    "iso8859-2":    charmap.ISO8859_2,
+    "iso8859-3":    charmap.ISO8859_3,
+    "iso8859-4":    charmap.ISO8859_4,
+    "iso8859-5":    charmap.ISO8859_5,
+    "iso8859-6":    charmap.ISO8859_6,
+    "iso8859-7":    charmap.ISO8859_7,
+    "iso8859-8":    charmap.ISO8859_8,
+    "iso8859-9":    charmap.ISO8859_9,
+    "iso8859-10":   charmap.ISO8859_10,
+    "iso8859-11":   charmap.Windows874,
+    "iso8859-13":   charmap.ISO8859_13,
+    "iso8859-14":   charmap.ISO8859_14,
+    "iso8859-15":   charmap.ISO8859_15,
+    "iso8859-16":   charmap.ISO8859_16,
+    "windows-1250": charmap.Windows1250,
+    "windows-1251": charmap.Windows1251,
+    "windows-1252": charmap.Windows1252,
+    "windows-1253": charmap.Windows1253,
+    "windows-1254": charmap.Windows1254,
+    "windows-1255": charmap.Windows1255,
+    "windows-1256": charmap.Windows1256,
+    "windows-1257": charmap.Windows1257,
+    "windows-1258": charmap.Windows1258,
+    "koi8-r":       charmap.KOI8R,
+    "koi8-u":       charmap.KOI8U,
+    "shift-jis":    japanese.ShiftJIS,
+    "euc-jp":       japanese.EUCJP,
+    "gbk":          simplifiedchinese.GBK,
+    "gb18030":      simplifiedchinese.GB18030,
+    "big5":         traditionalchinese.Big5,
+    "euc-kr":       korean.EUCKR,
 }
 
 
+// Common names which are not IANA aliases.
+var encodingaliases = map[string]string {
+    "cp1250":       "windows-1250",
+    "cp1251":       "windows-1251",
+    "cp1252":       "windows-1252",
+    "cp1253":       "windows-1253",
+    "cp1254":       "windows-1254",
+    "cp1255":       "windows-1255",
+    "cp1256":       "windows-1256",
+    "cp1257":       "windows-1257",
+    "cp1258":       "windows-1258",
+    "sjis":         "shift-jis",
+    "eucjp":        "euc-jp",
+    "euckr":        "euc-kr",
+}
+
+
+// Find an encoding by name, ignoring case, or by alias.  IANA names and
+// aliases, e.g. 'latin1' and 'ISO-8859-1', are recognised for the encodings
+// in charmaps.
 func find(name string) (e encoding.Encoding, err error) {
-    e, ok := charmaps[name]
-    if !ok {
-        return nil, fmt.Errorf("Unrecognised encoding %s", name)
+    name1 := strings.ToLower(name)
+    if alias, ok := encodingaliases[name1]; ok {
+        name1 = alias
+    }
+
+    if e, ok := charmaps[name1]; ok {
+        return e, nil
+    }
+
+    if e, err = ianaindex.IANA.Encoding(name); err == nil && e != nil {
+        for _, e1 := range charmaps {
+            if e1 == e {
+                return e, nil
+            }
+        }
+    }
+
+    return nil, fmt.Errorf("Unrecognised encoding %s", name)
+}
+
+
+// List the encoding names, sorted, with any aliases which are not IANA
+// aliases.  Note utf-8 is not listed as it needs no conversion.
+func listencodings(w io.Writer) {
+    names := []string{}
+    for name := range charmaps {
+        names = append(names, name)
     }
-    return e, nil
+    sort.Strings(names)
+
+    for _, name := range names {
+        aliases := []string{}
+        for alias, name1 := range encodingaliases {
+            if name1 == name {
+                aliases = append(aliases, alias)
+            }
+        }
+        sort.Strings(aliases)
+
+        if len(aliases) == 0 {
+            fmt.Fprintf(w, "%s\n", name)
+            continue
+        }
+        fmt.Fprintf(w, "%-14s%s\n", name, strings.Join(aliases, ", "))
+    }
+}
+
+
+// Decode s from e to UTF-8.
+func decode(e encoding.Encoding, s string) (s1 string, err error) {
+    return e.NewDecoder().String(s)
 }
 
 
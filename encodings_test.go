@@ -21,8 +21,31 @@ func Test_knownencoding(t *testing.T) {
 }
 
 
+func Test_knownencodingalias(t *testing.T) {
+    for _, name := range []string{ "ISO8859-1", "latin1", "ISO-8859-1" } {
+        e, err := find(name)
+        if ! (e == charmap.ISO8859_1 && err == nil) {
+            t.Errorf("Test_knownencodingalias:  failed %s", name)
+            return
+        }
+    }
+
+    e, err := find("cp1252")
+    if ! (e == charmap.Windows1252 && err == nil) {
+        t.Errorf("Test_knownencodingalias:  failed cp1252")
+        return
+    }
+
+    e, err = find("iso8859-9")
+    if ! (e == charmap.ISO8859_9 && err == nil) {
+        t.Errorf("Test_knownencodingalias:  failed iso8859-9")
+        return
+    }
+}
+
+
 func Test_unknownencoding(t *testing.T) {
-    e, err := find("iso8859-9")
+    e, err := find("iso8859-12")
     if ! (e == nil && err != nil) {
         t.Errorf("Test_unknownencoding:  failed")
         return
@@ -30,6 +53,16 @@ func Test_unknownencoding(t *testing.T) {
 }
 
 
+func Test_unknownencodingiana(t *testing.T) {
+    // UTF-16 is known to IANA but is not usable for id3v1 tags.
+    e, err := find("utf-16")
+    if ! (e == nil && err != nil) {
+        t.Errorf("Test_unknownencodingiana:  failed")
+        return
+    }
+}
+
+
 func Test_convertsuccessful(t *testing.T) {
     e, err := find("iso8859-1")
     if ! (e == charmap.ISO8859_1 && err == nil) {
@@ -73,3 +106,43 @@ func Test_convertunsupportedcharacter(t *testing.T) {
         return
     }
 }
+
+
+func Test_converttransliterated(t *testing.T) {
+    e, err := find("iso8859-1")
+    if ! (e == charmap.ISO8859_1 && err == nil) {
+        t.Errorf("Test_converttransliterated:  failed")
+        return
+    }
+
+    for _, test := range []struct {
+        s string
+        s1 string
+    }{
+        { "Dvořák", "Dvor\xe1k" },
+        { "Чайковский", "Chaykovskiy" },
+        { "Μίκης Θεοδωράκης", "Mikis Theodorakis" },
+        { "Œuvres ﬁnales", "OEuvres finales" },
+        { "東京", "??" },
+    } {
+        s1, err := convert(e, transliterate(e, test.s), '?')
+        if ! (s1 == test.s1 && err == nil) {
+            t.Errorf("Test_converttransliterated:  failed %q", s1)
+            return
+        }
+    }
+}
+
+
+func Test_normalisationform(t *testing.T) {
+    f, err := findnormalisationform("NFC")
+    if ! (err == nil && f.String("Bjo\u0308rk") == "Bj\u00f6rk") {
+        t.Errorf("Test_normalisationform:  failed")
+        return
+    }
+
+    _, err = findnormalisationform("nfx")
+    if ! (err != nil) {
+        t.Errorf("Test_normalisationform:  failed")
+    }
+}
@@ -0,0 +1,223 @@
+// 'encodingsdetect.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "sort"
+    "strings"
+    "unicode"
+
+    "golang.org/x/text/encoding"
+    "golang.org/x/text/encoding/korean"
+    xunicode "golang.org/x/text/encoding/unicode"
+)
+
+
+type encodingguess struct {
+    name string
+    encoding encoding.Encoding
+    score float64
+}
+
+
+// Commonest accented Latin, Cyrillic and Greek letters, lower case.
+const detectfrequentletters =
+    "éáíóúñüöäçèàãõâêôîûšžčćřěůőűąęłńśźżğışßåøæýť" +
+    "оеаинтсрвлкмдпуяыьгзбчйхжшюцщэфъёіїєґ" +
+    "αοειτνσςρηπλκμυάέίόύή"
+
+// Punctuation and symbols above ASCII which are common in titles.
+const detectcommonsymbols = "«»–—‘’“”…©®°·•"
+
+
+// Guess the encoding of s, a string of bytes in an unknown single or double
+// byte encoding, by decoding it with each candidate and scoring the result.
+// Letters score 1, frequent letters 2, CJK ideographs 3 and kana and hangul
+// 4, while other symbols, control characters and half-width katakana score
+// -1 or less.  Hangul outside the 2350 common syllables of KS X 1001 score
+// 0, as other encodings mis-decoded as windows-949 give these.  Words
+// switching from lower to upper case or mixing scripts are penalised, as are
+// Latin words without any ASCII letters and words mixing hangul and hanja, or
+// Latin and CJK.  The score is the average per byte above ASCII, so that the
+// two bytes of a CJK character count about the same as two single byte
+// letters.  Returns the candidates best first, preferring the commoner
+// encodings on a tie, or nil if s is ASCII.
+func detectencoding(s string) (guesses []encodingguess) {
+    n := 0
+    for j := 0; j < len(s); j++ {
+        if s[j] >= 0x80 {
+            n++
+        }
+    }
+    if n == 0 {
+        return nil
+    }
+
+    names := []string{}
+    for name := range charmaps {
+        if detectpreference(name) == len(detectpreferred) {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    names = append(append([]string{}, detectpreferred...), names...)
+
+    for _, name := range names {
+        e := xunicode.UTF8
+        if name != "utf-8" {
+            e = charmaps[name]
+        }
+
+        s1, err := decode(e, s)
+        if err != nil || strings.ContainsRune(s1, unicode.ReplacementChar) {
+            continue
+        }
+
+        // Valid multibyte UTF-8 is unlikely by chance, so score it per
+        // character instead.
+        n1 := n
+        if name == "utf-8" {
+            n1 = 0
+            for _, r := range s1 {
+                if r >= 0x80 {
+                    n1++
+                }
+            }
+        }
+
+        guesses = append(guesses, encodingguess{ name: name,
+                                                 encoding: e,
+                                                 score: detectscore(s1, n1) })
+    }
+
+    sort.SliceStable(guesses, func(j int, k int) bool {
+        return guesses[j].score > guesses[k].score
+    })
+
+    return guesses
+}
+
+
+// Commoner encodings in order of preference.
+var detectpreferred = []string {
+    "utf-8",
+    "windows-1252",
+    "windows-1251",
+    "koi8-r",
+    "iso8859-2",
+    "shift-jis",
+    "gbk",
+    "big5",
+    "euc-kr",
+    "euc-jp",
+}
+
+
+func detectpreference(name string) int {
+    for j, name1 := range detectpreferred {
+        if name1 == name {
+            return j
+        }
+    }
+    return len(detectpreferred)
+}
+
+
+// Score s, decoded from n bytes above ASCII.
+func detectscore(s string, n int) (score float64) {
+    total := 0
+
+    for _, word := range strings.FieldsFunc(s, unicode.IsSpace) {
+        var previous rune
+        latin, cyrillic, greek := false, false, false
+        ascii, latinaccented := false, 0
+        han, hangul, cjk := false, 0, 0
+
+        for _, r := range word {
+            if r >= 0x80 {
+                total += detectweight(r)
+            }
+
+            if unicode.IsUpper(r) && unicode.IsLower(previous) &&
+               (r >= 0x80 || previous >= 0x80) {
+                total -= 2
+            }
+            previous = r
+
+            switch {
+                case unicode.Is(unicode.Latin, r):
+                    latin = true
+                    if r < 0x80 {
+                        ascii = true
+                    } else {
+                        latinaccented++
+                    }
+                case unicode.Is(unicode.Cyrillic, r):
+                    cyrillic = true
+                case unicode.Is(unicode.Greek, r):
+                    greek = true
+                case unicode.Is(unicode.Han, r):
+                    han = true
+                    cjk++
+                case unicode.Is(unicode.Hangul, r):
+                    hangul++
+                    cjk++
+                case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+                    cjk++
+            }
+        }
+
+        if latin && cyrillic || latin && greek || cyrillic && greek {
+            total -= 2
+        }
+        if latin && !ascii {
+            total -= latinaccented
+        }
+        // Korean words seldom mix hangul and hanja, unlike Chinese
+        // mis-decoded as Korean.
+        if han && hangul > 0 {
+            total -= 3 * hangul
+        }
+        if latin && cjk > 0 {
+            total -= 3 * cjk
+        }
+    }
+
+    return float64(total) / float64(n)
+}
+
+
+func detectweight(r rune) int {
+    switch {
+        case r >= 0xff61 && r <= 0xff9f:
+            // Half-width katakana are rare outside of mis-decoded bytes.
+            return -1
+        case unicode.Is(unicode.Hangul, r) && !detectcommonhangul(r):
+            return 0
+        case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+            return 4
+        case unicode.Is(unicode.Han, r):
+            return 3
+        case unicode.IsLetter(r):
+            if strings.ContainsRune(detectfrequentletters, unicode.ToLower(r)) {
+                return 2
+            }
+            return 1
+        case unicode.IsControl(r):
+            return -5
+        case strings.ContainsRune(detectcommonsymbols, r):
+            return 0
+    }
+    return -1
+}
+
+
+// Whether r is one of the hangul in KS X 1001, i.e. EUC-KR proper, which has
+// both bytes from 0xa1.
+func detectcommonhangul(r rune) bool {
+    bytes, err := korean.EUCKR.NewEncoder().Bytes([]byte(string(r)))
+    return err == nil && len(bytes) == 2 && bytes[0] >= 0xa1 && bytes[1] >= 0xa1
+}
@@ -0,0 +1,120 @@
+// 'encodingsdetect_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "testing"
+
+    "golang.org/x/text/encoding"
+)
+
+
+func detectencodingname(t *testing.T, name string, s string) string {
+    e, err := find(name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    s1, err := encoding.ReplaceUnsupported(e.NewEncoder()).String(s)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    guesses := detectencoding(s1)
+    if len(guesses) == 0 {
+        return ""
+    }
+    return guesses[0].name
+}
+
+
+func Test_detectencodingascii(t *testing.T) {
+    if guesses := detectencoding("Abbey Road"); guesses != nil {
+        t.Errorf("Test_detectencodingascii:  failed")
+        return
+    }
+}
+
+
+func Test_detectencodingutf8(t *testing.T) {
+    guesses := detectencoding("Sigur Rós Ágætis byrjun")
+    if ! (len(guesses) > 0 && guesses[0].name == "utf-8") {
+        t.Errorf("Test_detectencodingutf8:  failed")
+        return
+    }
+}
+
+
+func Test_detectencodingwindows1252(t *testing.T) {
+    name := detectencodingname(t, "windows-1252", "Café del Mar Señor")
+    if name != "windows-1252" {
+        t.Errorf("Test_detectencodingwindows1252:  failed %s", name)
+        return
+    }
+}
+
+
+func Test_detectencodingwindows1251(t *testing.T) {
+    name := detectencodingname(t, "windows-1251", "Звезда по имени Солнце")
+    if name != "windows-1251" {
+        t.Errorf("Test_detectencodingwindows1251:  failed %s", name)
+        return
+    }
+}
+
+
+func Test_detectencodingkoi8r(t *testing.T) {
+    name := detectencodingname(t, "koi8-r", "Группа крови")
+    if name != "koi8-r" {
+        t.Errorf("Test_detectencodingkoi8r:  failed %s", name)
+        return
+    }
+}
+
+
+func Test_detectencodingiso88592(t *testing.T) {
+    name := detectencodingname(t, "iso8859-2", "Zażółć gęślą jaźń Łódź")
+    if name != "iso8859-2" {
+        t.Errorf("Test_detectencodingiso88592:  failed %s", name)
+        return
+    }
+}
+
+
+func Test_detectencodingshiftjis(t *testing.T) {
+    name := detectencodingname(t, "shift-jis", "東京事変 群青日和")
+    if name != "shift-jis" {
+        t.Errorf("Test_detectencodingshiftjis:  failed %s", name)
+        return
+    }
+}
+
+
+func Test_detectencodingwindows1253(t *testing.T) {
+    name := detectencodingname(t, "windows-1253", "Καλημέρα κόσμε")
+    if name != "iso8859-7" && name != "windows-1253" {
+        t.Errorf("Test_detectencodingwindows1253:  failed %s", name)
+        return
+    }
+}
+
+
+func Test_detectencodinggbk(t *testing.T) {
+    name := detectencodingname(t, "gbk", "我的中国心")
+    if name != "gbk" {
+        t.Errorf("Test_detectencodinggbk:  failed %s", name)
+        return
+    }
+}
+
+
+func Test_detectencodingeuckr(t *testing.T) {
+    name := detectencodingname(t, "euc-kr", "사랑해요")
+    if name != "euc-kr" {
+        t.Errorf("Test_detectencodingeuckr:  failed %s", name)
+        return
+    }
+}
@@ -6,7 +6,11 @@ package main
 
 
 import (
+    "bytes"
     "fmt"
+    "strings"
+
+    "golang.org/x/text/encoding"
 )
 
 
@@ -15,8 +19,12 @@ import (
 //    128 bytes from the end of the file.
 //    Strings are either space- or zero-padded.
 //    Unset string entries are filled using an empty string."
+// Id3v1.1 takes the last two bytes of the comment for a zero byte and the
+// track, so revision is 1 for id3v1.1 and 0 for id3v1.0, which has no track
+// and is written without one.
 type id3v1 struct {
     header string
+    revision byte
     title string
     artist string
     album string
@@ -35,18 +43,60 @@ func newid3v1frombytes(bytes []byte) (i *id3v1, err error) {
         return nil, fmt.Errorf("Unable to find id3v1 header.")
     }
 
-    i.title = string(bytes[3:33])
-    i.artist = string(bytes[33:63])
-    i.album = string(bytes[63:93])
-    i.year = string(bytes[93:97])
-    i.comment = string(bytes[97:125])
-    i.track = bytes[126]
+    i.title = id3v1string(bytes[3:33])
+    i.artist = id3v1string(bytes[33:63])
+    i.album = id3v1string(bytes[63:93])
+    i.year = id3v1string(bytes[93:97])
+    if bytes[125] == 0 && bytes[126] != 0 {
+        i.revision = 1
+        i.comment = id3v1string(bytes[97:125])
+        i.track = bytes[126]
+    } else {
+        i.revision = 0
+        i.comment = id3v1string(bytes[97:127])
+        i.track = 0
+    }
     i.genre = bytes[127]
 
     return i, nil
 }
 
 
+// Strings end at the first zero byte, with any trailing spaces removed.
+func id3v1string(b []byte) string {
+    if j := bytes.IndexByte(b, 0); j != -1 {
+        b = b[0:j]
+    }
+    return strings.TrimRight(string(b), " ")
+}
+
+
+func (i *id3v1) strings() []*string {
+    return []*string{ &i.title, &i.artist, &i.album, &i.year, &i.comment }
+}
+
+
+// Decode the strings from e, as read from the tag, to UTF-8.
+func (i *id3v1) decode(e encoding.Encoding) (err error) {
+    for _, s := range i.strings() {
+        if *s, err = decode(e, *s); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+
+// Guess the encoding of the strings as read from the tag.
+func (i *id3v1) detectencoding() (guesses []encodingguess) {
+    texts := []string{}
+    for _, s := range i.strings() {
+        texts = append(texts, *s)
+    }
+    return detectencoding(strings.Join(texts, " "))
+}
+
+
 func newid3v1fromitems(title string,
                        artist string,
                        album string,
@@ -54,7 +104,13 @@ func newid3v1fromitems(title string,
                        comment string,
                        track byte,
                        genre byte) (i *id3v1) {
+    var revision byte
+    if track != 0 {
+        revision = 1
+    }
+
     return &id3v1{ header: "TAG",
+                   revision: revision,
                    title: title,
                    artist: artist,
                    album: album,
@@ -72,9 +128,13 @@ func (i *id3v1)bytes() []byte {
     copy(bytes[33:63], i.artist)
     copy(bytes[63:93], i.album)
     copy(bytes[93:97], i.year)
-    copy(bytes[97:125], i.comment)
-    bytes[125] = 0
-    bytes[126] = i.track
+    if i.revision == 1 {
+        copy(bytes[97:125], i.comment)
+        bytes[125] = 0
+        bytes[126] = i.track
+    } else {
+        copy(bytes[97:127], i.comment)
+    }
     bytes[127] = i.genre
     return bytes
 }
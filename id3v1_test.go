@@ -0,0 +1,178 @@
+// 'id3v1_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "strings"
+    "testing"
+)
+
+
+func Test_id3v10(t *testing.T) {
+    comment := strings.Repeat("c", 30)
+    i := newid3v1fromitems("Title", "Artist", "Album", "1970", comment, 0, 255)
+
+    i1, err := newid3v1frombytes(i.bytes())
+    if ! (err == nil &&
+          i1.revision == 0 &&
+          i1.comment == comment &&
+          i1.track == 0) {
+        t.Errorf("Test_id3v10:  failed")
+        return
+    }
+}
+
+
+func Test_id3v11(t *testing.T) {
+    comment := strings.Repeat("c", 28)
+    i := newid3v1fromitems("Title", "Artist", "Album", "1970", comment, 7, 255)
+
+    i1, err := newid3v1frombytes(i.bytes())
+    if ! (err == nil &&
+          i1.revision == 1 &&
+          i1.comment == comment &&
+          i1.track == 7) {
+        t.Errorf("Test_id3v11:  failed")
+        return
+    }
+}
+
+
+// Revision decides the layout, not the track.
+func Test_id3v1revision(t *testing.T) {
+    comment := strings.Repeat("c", 30)
+    i := newid3v1fromitems("Title", "Artist", "Album", "1970", comment, 7, 255)
+    i.revision = 0
+
+    i1, err := newid3v1frombytes(i.bytes())
+    if ! (err == nil &&
+          i1.revision == 0 &&
+          i1.comment == comment &&
+          i1.track == 0) {
+        t.Errorf("Test_id3v1revision:  failed")
+        return
+    }
+
+    i = newid3v1fromitems("Title", "Artist", "Album", "1970", comment, 0, 255)
+    i.revision = 1
+    bytes := i.bytes()
+    if ! (bytes[125] == 0 &&
+          bytes[126] == 0 &&
+          string(bytes[97:125]) == comment[0:28]) {
+        t.Errorf("Test_id3v1revision:  failed")
+        return
+    }
+}
+
+
+func Test_genres(t *testing.T) {
+    genre, ok := findgenre("rock")
+    if ! (ok && genre == 17 && genretcon("rock") == "(17)Rock") {
+        t.Errorf("Test_genres:  failed")
+        return
+    }
+
+    names := tcongenres([]string{ "(17)Rock", "(4)Eurodisco", "(RX)", "((a)" })
+    if strings.Join(names, "/") != "Rock/Disco/Eurodisco/Remix/(a)" {
+        t.Errorf("Test_genres:  failed %v", names)
+        return
+    }
+}
+
+
+func Test_id3v1extended(t *testing.T) {
+    title := strings.Repeat("t", 30) + "Continued"
+    i := newid3v1fromitems(title, "Artist", "Album", "1970", "", 7, 255)
+    e := newid3v1extendedfromitems(nil, title, "Artist", "Album", "Chillwave")
+
+    i1, err := newid3v1frombytes(i.bytes())
+    if err != nil {
+        t.Errorf("Test_id3v1extended:  failed %s", err)
+        return
+    }
+    e1, err := newid3v1extendedfrombytes(e.bytes())
+    if err != nil {
+        t.Errorf("Test_id3v1extended:  failed %s", err)
+        return
+    }
+
+    e1.merge(i1, i.bytes())
+    if ! (i1.title == title &&
+          i1.artist == "Artist" &&
+          e1.genre == "Chillwave") {
+        t.Errorf("Test_id3v1extended:  failed")
+        return
+    }
+}
+
+
+// A space at the end of an id3v1 field continued by the extended tag is kept.
+func Test_id3v1extendedspace(t *testing.T) {
+    title := strings.Repeat("t", 29) + " Continued  "
+    i := newid3v1fromitems(title, "Artist", "Album", "1970", "", 7, 255)
+    e := newid3v1extendedfromitems(nil, title, "Artist", "Album", "")
+
+    bytes := i.bytes()
+    i1, _ := newid3v1frombytes(bytes)
+    e1, _ := newid3v1extendedfrombytes(e.bytes())
+    e1.merge(i1, bytes)
+    if ! (i1.title == strings.TrimRight(title, " ") &&
+          i1.artist == "Artist") {
+        t.Errorf("Test_id3v1extendedspace:  failed, %q", i1.title)
+        return
+    }
+}
+
+
+// Fields are split at a character boundary, not inside one.
+func Test_id3v1split(t *testing.T) {
+    title := strings.Repeat("t", 29) + "\u00e9t\u00e9"
+    head, rest := id3v1split(nil, title, 30)
+    if ! (head == strings.Repeat("t", 29) && rest == "\u00e9t\u00e9") {
+        t.Errorf("Test_id3v1split:  failed")
+        return
+    }
+
+    e, _ := find("shift_jis")
+    title, _ = convert(e, strings.Repeat("t", 29) + "\u65e5\u672c", '?')
+    head, rest = id3v1split(e, title, 30)
+    if ! (head == strings.Repeat("t", 29) && len(rest) == 4) {
+        t.Errorf("Test_id3v1split:  failed")
+        return
+    }
+
+    e, _ = find("windows-1252")
+    title, _ = convert(e, strings.Repeat("t", 29) + "\u00e9t\u00e9", '?')
+    if head, rest = id3v1split(e, title, 30); len(head) != 30 {
+        t.Errorf("Test_id3v1split:  failed")
+        return
+    }
+
+    i := newid3v1extendedfromitems(e, title, "", "", "")
+    if i.title != rest {
+        t.Errorf("Test_id3v1split:  failed")
+        return
+    }
+}
+
+
+func Test_id3v1decode(t *testing.T) {
+    i := newid3v1fromitems("S\xf3ng \x80", "Artist  ", "", "1970", "", 7, 255)
+    bytes := i.bytes()
+    copy(bytes[40:63], "\x00junk")
+
+    e, _ := find("windows-1252")
+    i1, err := newid3v1frombytes(bytes)
+    if err == nil {
+        err = i1.decode(e)
+    }
+    if ! (err == nil &&
+          i1.title == "Sóng €" &&
+          i1.artist == "Artist") {
+        t.Errorf("Test_id3v1decode:  failed")
+        return
+    }
+}
@@ -0,0 +1,191 @@
+// 'id3v1extended.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "fmt"
+    "strings"
+    "unicode/utf8"
+
+    "golang.org/x/text/encoding"
+)
+
+
+// See:  https://en.wikipedia.org/wiki/ID3#Enhanced_tag
+// The enhanced tag is 227 bytes beginning with the string TAG+, immediately
+// before the id3v1 tag.  Title, artist and album continue the id3v1 fields
+// to 90 characters.
+type id3v1extended struct {
+    header string
+    title string
+    artist string
+    album string
+    speed byte
+    genre string
+    starttime string
+    endtime string
+}
+
+
+const id3v1extendedsize = 227
+
+
+var id3v1extendedspeeds = []string {
+    "unset",                                            // 0.
+    "slow",                                             // 1.
+    "medium",                                           // 2.
+    "fast",                                             // 3.
+    "hardcore",                                         // 4.
+}
+
+
+// Bytes are:
+// 0..3:     'TAG+'.
+// 4..63:    title.
+// 64..123:  artist.
+// 124..183: album.
+// 184:      speed.
+// 185..214: genre, free text.
+// 215..220: start time, 'mmm:ss'.
+// 221..226: end time, 'mmm:ss'.
+func newid3v1extendedfrombytes(bytes []byte) (e *id3v1extended, err error) {
+    if len(bytes) != id3v1extendedsize || string(bytes[0:4]) != "TAG+" {
+        return nil, fmt.Errorf("Unable to find id3v1 extended header.")
+    }
+
+    e = new(id3v1extended)
+    e.header = string(bytes[0:4])
+    e.title = id3v1string(bytes[4:64])
+    e.artist = id3v1string(bytes[64:124])
+    e.album = id3v1string(bytes[124:184])
+    e.speed = bytes[184]
+    e.genre = id3v1string(bytes[185:215])
+    e.starttime = id3v1string(bytes[215:221])
+    e.endtime = id3v1string(bytes[221:227])
+
+    return e, nil
+}
+
+
+// Title, artist and album are the whole values, of which the first 30 bytes
+// belong in the id3v1 tag, see id3v1split().  Values are in encoding e, or
+// UTF-8 if e is nil.
+func newid3v1extendedfromitems(e encoding.Encoding,
+                               title string,
+                               artist string,
+                               album string,
+                               genre string) (e1 *id3v1extended) {
+    rest := func(s string) string {
+        _, s = id3v1split(e, s, 30)
+        s, _ = id3v1split(e, s, 60)
+        return s
+    }
+    genre, _ = id3v1split(e, genre, 30)
+
+    return &id3v1extended{ header: "TAG+",
+                           title: rest(title),
+                           artist: rest(artist),
+                           album: rest(album),
+                           genre: genre }
+}
+
+
+// Split s, in encoding e or UTF-8 if e is nil, at the last character boundary
+// within n bytes.  For other encodings a boundary is where both parts decode
+// to the same as the whole, which a split character does not.
+func id3v1split(e encoding.Encoding, s string, n int) (head string, rest string) {
+    if len(s) <= n {
+        return s, ""
+    }
+
+    var whole string
+    if e != nil {
+        whole, _ = decode(e, s)
+    }
+
+    for k := n; k > 0; k-- {
+        if e == nil {
+            if utf8.RuneStart(s[k]) {
+                return s[0:k], s[k:]
+            }
+            continue
+        }
+
+        head, err := decode(e, s[0:k])
+        rest, err1 := decode(e, s[k:])
+        if err == nil && err1 == nil && head + rest == whole {
+            return s[0:k], s[k:]
+        }
+    }
+
+    return s[0:n], s[n:]
+}
+
+
+func (e *id3v1extended) bytes() []byte {
+    bytes := make([]byte, id3v1extendedsize)
+    copy(bytes[0:4], "TAG+")
+    copy(bytes[4:64], e.title)
+    copy(bytes[64:124], e.artist)
+    copy(bytes[124:184], e.album)
+    bytes[184] = e.speed
+    copy(bytes[185:215], e.genre)
+    copy(bytes[215:221], e.starttime)
+    copy(bytes[221:227], e.endtime)
+    return bytes
+}
+
+
+func (e *id3v1extended) speedname() string {
+    if int(e.speed) < len(id3v1extendedspeeds) {
+        return id3v1extendedspeeds[e.speed]
+    }
+    return fmt.Sprintf("%d", e.speed)
+}
+
+
+func (e *id3v1extended) strings() []*string {
+    return []*string{ &e.title, &e.artist, &e.album, &e.genre }
+}
+
+
+// Decode the strings from charset, as read from the tag, to UTF-8.
+func (e *id3v1extended) decode(charset encoding.Encoding) (err error) {
+    for _, s := range e.strings() {
+        if *s, err = decode(charset, *s); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+
+// Guess the encoding of the strings as read from the tag.
+func (e *id3v1extended) detectencoding() (guesses []encodingguess) {
+    texts := []string{}
+    for _, s := range e.strings() {
+        texts = append(texts, *s)
+    }
+    return detectencoding(strings.Join(texts, " "))
+}
+
+
+// Continue the id3v1 title, artist and album, as read from bytes, the id3v1
+// tag, with the extended fields.  Note spaces at the end of an id3v1 field
+// may be part of the value, so only the whole value is trimmed.  Note both
+// tags should be merged before decoding.
+func (e *id3v1extended) merge(i *id3v1, bytes []byte) {
+    merge := func(b []byte, s string) string {
+        if j := strings.IndexByte(string(b), 0); j != -1 {
+            b = b[0:j]
+        }
+        return strings.TrimRight(string(b) + s, " ")
+    }
+
+    i.title = merge(bytes[3:33], e.title)
+    i.artist = merge(bytes[33:63], e.artist)
+    i.album = merge(bytes[63:93], e.album)
+}
@@ -0,0 +1,306 @@
+// 'id3v1genres.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+
+// See:  https://en.wikipedia.org/wiki/List_of_ID3v1_Genres
+// Genre 255 means no genre.
+var id3v1genres = []string {
+    // Id3v1.
+    "Blues",                                            // 0.
+    "Classic Rock",                                     // 1.
+    "Country",                                          // 2.
+    "Dance",                                            // 3.
+    "Disco",                                            // 4.
+    "Funk",                                             // 5.
+    "Grunge",                                           // 6.
+    "Hip-Hop",                                          // 7.
+    "Jazz",                                             // 8.
+    "Metal",                                            // 9.
+    "New Age",                                          // 10.
+    "Oldies",                                           // 11.
+    "Other",                                            // 12.
+    "Pop",                                              // 13.
+    "R&B",                                              // 14.
+    "Rap",                                              // 15.
+    "Reggae",                                           // 16.
+    "Rock",                                             // 17.
+    "Techno",                                           // 18.
+    "Industrial",                                       // 19.
+    "Alternative",                                      // 20.
+    "Ska",                                              // 21.
+    "Death Metal",                                      // 22.
+    "Pranks",                                           // 23.
+    "Soundtrack",                                       // 24.
+    "Euro-Techno",                                      // 25.
+    "Ambient",                                          // 26.
+    "Trip-Hop",                                         // 27.
+    "Vocal",                                            // 28.
+    "Jazz+Funk",                                        // 29.
+    "Fusion",                                           // 30.
+    "Trance",                                           // 31.
+    "Classical",                                        // 32.
+    "Instrumental",                                     // 33.
+    "Acid",                                             // 34.
+    "House",                                            // 35.
+    "Game",                                             // 36.
+    "Sound Clip",                                       // 37.
+    "Gospel",                                           // 38.
+    "Noise",                                            // 39.
+    "AlternRock",                                       // 40.
+    "Bass",                                             // 41.
+    "Soul",                                             // 42.
+    "Punk",                                             // 43.
+    "Space",                                            // 44.
+    "Meditative",                                       // 45.
+    "Instrumental Pop",                                 // 46.
+    "Instrumental Rock",                                // 47.
+    "Ethnic",                                           // 48.
+    "Gothic",                                           // 49.
+    "Darkwave",                                         // 50.
+    "Techno-Industrial",                                // 51.
+    "Electronic",                                       // 52.
+    "Pop-Folk",                                         // 53.
+    "Eurodance",                                        // 54.
+    "Dream",                                            // 55.
+    "Southern Rock",                                    // 56.
+    "Comedy",                                           // 57.
+    "Cult",                                             // 58.
+    "Gangsta",                                          // 59.
+    "Top 40",                                           // 60.
+    "Christian Rap",                                    // 61.
+    "Pop/Funk",                                         // 62.
+    "Jungle",                                           // 63.
+    "Native American",                                  // 64.
+    "Cabaret",                                          // 65.
+    "New Wave",                                         // 66.
+    "Psychadelic",                                      // 67.
+    "Rave",                                             // 68.
+    "Showtunes",                                        // 69.
+    "Trailer",                                          // 70.
+    "Lo-Fi",                                            // 71.
+    "Tribal",                                           // 72.
+    "Acid Punk",                                        // 73.
+    "Acid Jazz",                                        // 74.
+    "Polka",                                            // 75.
+    "Retro",                                            // 76.
+    "Musical",                                          // 77.
+    "Rock & Roll",                                      // 78.
+    "Hard Rock",                                        // 79.
+
+    // Winamp extensions.
+    "Folk",                                             // 80.
+    "Folk-Rock",                                        // 81.
+    "National Folk",                                    // 82.
+    "Swing",                                            // 83.
+    "Fast Fusion",                                      // 84.
+    "Bebob",                                            // 85.
+    "Latin",                                            // 86.
+    "Revival",                                          // 87.
+    "Celtic",                                           // 88.
+    "Bluegrass",                                        // 89.
+    "Avantgarde",                                       // 90.
+    "Gothic Rock",                                      // 91.
+    "Progressive Rock",                                 // 92.
+    "Psychedelic Rock",                                 // 93.
+    "Symphonic Rock",                                   // 94.
+    "Slow Rock",                                        // 95.
+    "Big Band",                                         // 96.
+    "Chorus",                                           // 97.
+    "Easy Listening",                                   // 98.
+    "Acoustic",                                         // 99.
+    "Humour",                                           // 100.
+    "Speech",                                           // 101.
+    "Chanson",                                          // 102.
+    "Opera",                                            // 103.
+    "Chamber Music",                                    // 104.
+    "Sonata",                                           // 105.
+    "Symphony",                                         // 106.
+    "Booty Bass",                                       // 107.
+    "Primus",                                           // 108.
+    "Porn Groove",                                      // 109.
+    "Satire",                                           // 110.
+    "Slow Jam",                                         // 111.
+    "Club",                                             // 112.
+    "Tango",                                            // 113.
+    "Samba",                                            // 114.
+    "Folklore",                                         // 115.
+    "Ballad",                                           // 116.
+    "Power Ballad",                                     // 117.
+    "Rhythmic Soul",                                    // 118.
+    "Freestyle",                                        // 119.
+    "Duet",                                             // 120.
+    "Punk Rock",                                        // 121.
+    "Drum Solo",                                        // 122.
+    "A capella",                                        // 123.
+    "Euro-House",                                       // 124.
+    "Dance Hall",                                       // 125.
+    "Goa",                                              // 126.
+    "Drum & Bass",                                      // 127.
+    "Club-House",                                       // 128.
+    "Hardcore",                                         // 129.
+    "Terror",                                           // 130.
+    "Indie",                                            // 131.
+    "BritPop",                                          // 132.
+    "Negerpunk",                                        // 133.
+    "Polsk Punk",                                       // 134.
+    "Beat",                                             // 135.
+    "Christian Gangsta Rap",                            // 136.
+    "Heavy Metal",                                      // 137.
+    "Black Metal",                                      // 138.
+    "Crossover",                                        // 139.
+    "Contemporary Christian",                           // 140.
+    "Christian Rock",                                   // 141.
+    "Merengue",                                         // 142.
+    "Salsa",                                            // 143.
+    "Thrash Metal",                                     // 144.
+    "Anime",                                            // 145.
+    "JPop",                                             // 146.
+    "Synthpop",                                         // 147.
+
+    // Later Winamp extensions.
+    "Abstract",                                         // 148.
+    "Art Rock",                                         // 149.
+    "Baroque",                                          // 150.
+    "Bhangra",                                          // 151.
+    "Big Beat",                                         // 152.
+    "Breakbeat",                                        // 153.
+    "Chillout",                                         // 154.
+    "Downtempo",                                        // 155.
+    "Dub",                                              // 156.
+    "EBM",                                              // 157.
+    "Eclectic",                                         // 158.
+    "Electro",                                          // 159.
+    "Electroclash",                                     // 160.
+    "Emo",                                              // 161.
+    "Experimental",                                     // 162.
+    "Garage",                                           // 163.
+    "Global",                                           // 164.
+    "IDM",                                              // 165.
+    "Illbient",                                         // 166.
+    "Industro-Goth",                                    // 167.
+    "Jam Band",                                         // 168.
+    "Krautrock",                                        // 169.
+    "Leftfield",                                        // 170.
+    "Lounge",                                           // 171.
+    "Math Rock",                                        // 172.
+    "New Romantic",                                     // 173.
+    "Nu-Breakz",                                        // 174.
+    "Post-Punk",                                        // 175.
+    "Post-Rock",                                        // 176.
+    "Psytrance",                                        // 177.
+    "Shoegaze",                                         // 178.
+    "Space Rock",                                       // 179.
+    "Trop Rock",                                        // 180.
+    "World Music",                                      // 181.
+    "Neoclassical",                                     // 182.
+    "Audiobook",                                        // 183.
+    "Audio Theatre",                                    // 184.
+    "Neue Deutsche Welle",                              // 185.
+    "Podcast",                                          // 186.
+    "Indie Rock",                                       // 187.
+    "G-Funk",                                           // 188.
+    "Dubstep",                                          // 189.
+    "Garage Rock",                                      // 190.
+    "Psybient",                                         // 191.
+}
+
+
+const id3v1genrenone = 255
+
+
+// Returns the name of genre, or "" for none or unrecognised.
+func genrename(genre byte) string {
+    if int(genre) < len(id3v1genres) {
+        return id3v1genres[genre]
+    }
+    return ""
+}
+
+
+// Find a genre by name, ignoring case, or by number.
+func findgenre(name string) (genre byte, ok bool) {
+    for j, name1 := range id3v1genres {
+        if strings.EqualFold(name1, name) {
+            return byte(j), true
+        }
+    }
+
+    n, err := strconv.Atoi(name)
+    if err == nil && n >= 0 && n < len(id3v1genres) {
+        return byte(n), true
+    }
+
+    return id3v1genrenone, false
+}
+
+
+// See:  http://id3.org/id3v2.3.0#TCON
+//       http://id3.org/id3v2.4.0-frames#TCON
+// Id3v2.3 refers to id3v1 genres as '(17)', possibly followed by a
+// refinement, with '(RX)' for remix, '(CR)' for cover and '((' escaping a
+// leading '('.  Id3v2.4 uses the plain number, 'RX' and 'CR' instead.
+// Returns the genre names, without a refinement that repeats its reference.
+func tcongenres(texts []string) (names []string) {
+    regexpreference := regexp.MustCompile(`^\(([0-9]+|RX|CR)\)`)
+
+    for _, text := range texts {
+        for {
+            result := regexpreference.FindStringSubmatch(text)
+            if result == nil {
+                break
+            }
+            names = append(names, tcongenre(result[1]))
+            text = text[len(result[0]):]
+        }
+
+        if strings.HasPrefix(text, "((") {
+            text = text[1:]
+        }
+        if text == "" ||
+           len(names) > 0 && strings.EqualFold(names[len(names) - 1], text) {
+            continue
+        }
+        names = append(names, tcongenre(text))
+    }
+
+    return names
+}
+
+
+func tcongenre(text string) string {
+    switch text {
+        case "RX":
+            return "Remix"
+        case "CR":
+            return "Cover"
+    }
+    if n, err := strconv.Atoi(text); err == nil && n >= 0 && n < 256 {
+        if name := genrename(byte(n)); name != "" {
+            return name
+        }
+    }
+    return text
+}
+
+
+// Returns the TCON text for a genre name, with the '(17)' reference for
+// id3v1 genres so that id3v2.3 readers understand it too.
+func genretcon(name string) string {
+    if genre, ok := findgenre(name); ok {
+        return "(" + strconv.Itoa(int(genre)) + ")" + genrename(genre)
+    }
+    if strings.HasPrefix(name, "(") {
+        return "(" + name
+    }
+    return name
+}
@@ -0,0 +1,545 @@
+// 'id3v2.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "encoding/binary"
+    "fmt"
+    "hash/crc32"
+    "strings"
+
+    "golang.org/x/text/encoding"
+    "golang.org/x/text/encoding/charmap"
+    "golang.org/x/text/encoding/unicode"
+)
+
+
+// See:  http://id3.org/id3v2.4.0-structure
+//       http://id3.org/id3v2.4.0-frames
+//       http://id3.org/id3v2.3.0
+type id3v2frame struct {
+    id string
+    flags uint16
+    group byte
+    data []byte
+}
+
+
+type id3v2 struct {
+    version byte
+    revision byte
+    flags byte
+    size int
+    frames []*id3v2frame
+    padding int
+    footer bool
+    extendedheader *id3v2extendedheader
+    compressthreshold int
+}
+
+
+const id3v2flagunsynchronisation = 0x80
+const id3v2flagextendedheader = 0x40
+const id3v2flagexperimental = 0x20
+const id3v2flagfooter = 0x10
+const id3v22flagcompression = 0x40
+
+const id3v23frameflagcompression = 0x0080
+const id3v23frameflagencryption = 0x0040
+const id3v23frameflaggrouping = 0x0020
+
+const id3v24frameflaggrouping = 0x0040
+const id3v24frameflagcompression = 0x0008
+const id3v24frameflagencryption = 0x0004
+const id3v24frameflagunsynchronisation = 0x0002
+const id3v24frameflagdatalengthindicator = 0x0001
+
+
+// Synchsafe integers have bit 7 of every byte zeroed, leaving 7 bits per byte.
+func synchsafeint(bytes []byte) (value int) {
+    for _, b := range bytes {
+        value = value << 7 | int(b & 0x7f)
+    }
+    return value
+}
+
+
+func synchsafebytes(value int, n int) (bytes []byte) {
+    bytes = make([]byte, n)
+    for i := n - 1; i >= 0; i-- {
+        bytes[i] = byte(value & 0x7f)
+        value >>= 7
+    }
+    return bytes
+}
+
+
+// Reverses unsynchronisation, i.e. replaces every $FF $00 with $FF.
+func resynchronise(bytes []byte) (bytes1 []byte) {
+    bytes1 = make([]byte, 0, len(bytes))
+    for j := 0; j < len(bytes); j++ {
+        bytes1 = append(bytes1, bytes[j])
+        if bytes[j] == 0xff && j + 1 < len(bytes) && bytes[j + 1] == 0x00 {
+            j++
+        }
+    }
+    return bytes1
+}
+
+
+// Returns the total size of an id3v2 tag given its ten byte header or footer,
+// including header and footer.
+func id3v2tagsize(bytes10 []byte) (size int) {
+    size = synchsafeint(bytes10[6:10]) + 10
+    if bytes10[3] == 4 && bytes10[5] & id3v2flagfooter != 0 {
+        size += 10
+    }
+    return size
+}
+
+
+func newid3v2frombytes(bytes []byte) (i *id3v2, err error) {
+
+    // First ten bytes are:
+    // 0:        'I'.
+    // 1:        'D'.
+    // 2:        '3'.
+    // 3:        version.
+    // 4:        revision.
+    // 5:        flags.
+    // 6..9:     size.
+
+    if len(bytes) < 10 || string(bytes[0:3]) != "ID3" {
+        return nil, fmt.Errorf("Unable to find id3v2 header.")
+    }
+
+    i = new(id3v2)
+    i.version = bytes[3]
+    i.revision = bytes[4]
+    i.flags = bytes[5]
+    i.size = synchsafeint(bytes[6:10])
+
+    if i.version < 2 || i.version > 4 {
+        return nil, fmt.Errorf("Unsupported id3v2 version 2.%d.", i.version)
+    }
+
+    if i.version == 2 && i.flags & id3v22flagcompression != 0 {
+        return nil, fmt.Errorf("Unsupported compressed id3v2.2 tag.")
+    }
+
+    if 10 + i.size > len(bytes) {
+        return nil, fmt.Errorf("Unable to parse truncated id3v2 tag.")
+    }
+
+    i.footer = i.version == 4 && i.flags & id3v2flagfooter != 0
+    if i.footer {
+        if 10 + i.size + 10 > len(bytes) ||
+           string(bytes[10 + i.size:10 + i.size + 3]) != "3DI" {
+            return nil, fmt.Errorf("Unable to find id3v2 footer.")
+        }
+    }
+
+    body := bytes[10:10 + i.size]
+
+    // Note id3v2.4 unsynchronisation is applied per frame instead.
+    if i.version < 4 && i.flags & id3v2flagunsynchronisation != 0 {
+        body = resynchronise(body)
+    }
+
+    if i.version == 2 {
+        if i.frames, body, err = newid3v22framesfrombytes(body); err != nil {
+            return nil, err
+        }
+        i.padding = len(body)
+        return i, nil
+    }
+
+    if i.flags & id3v2flagextendedheader != 0 {
+        var n int
+        if i.extendedheader, n, err =
+            newid3v2extendedheaderfrombytes(i.version, body); err != nil {
+            return nil, err
+        }
+        body = body[n:]
+
+        if i.extendedheader.crc {
+            data := body
+            if i.version == 3 && i.extendedheader.paddingsize <= len(data) {
+                data = data[:len(data) - i.extendedheader.paddingsize]
+            }
+            i.extendedheader.crc32calculated = crc32.ChecksumIEEE(data)
+        }
+    }
+
+    if i.frames, body, err =
+        newid3v2framesfrombytes(i.version,
+                                i.flags & id3v2flagunsynchronisation != 0,
+                                body); err != nil {
+        return nil, err
+    }
+
+    i.padding = len(body)
+
+    return i, nil
+}
+
+
+// New tags are id3v2.4.
+func newid3v2() (i *id3v2) {
+    return &id3v2{ version: 4 }
+}
+
+
+// Returns the frames and the remaining bytes, i.e. the padding.  Also used
+// for the subframes of CHAP and CTOC frames.
+func newid3v2framesfrombytes(version byte,
+                             unsynchronisation bool,
+                             body []byte) (frames []*id3v2frame,
+                                           padding []byte,
+                                           err error) {
+    for len(body) >= 10 && body[0] != 0 {
+        f := new(id3v2frame)
+        f.id = string(body[0:4])
+
+        var n int
+        if version == 3 {
+            n = int(binary.BigEndian.Uint32(body[4:8]))
+        } else {
+            n = synchsafeint(body[4:8])
+        }
+        f.flags = binary.BigEndian.Uint16(body[8:10])
+
+        if 10 + n > len(body) {
+            return nil, nil, fmt.Errorf("Unable to parse id3v2 frame %s.", f.id)
+        }
+
+        f.data = body[10:10 + n]
+        if err = f.decode(version, unsynchronisation); err != nil {
+            return nil, nil, err
+        }
+        frames = append(frames, f)
+        body = body[10 + n:]
+    }
+
+    return frames, body, nil
+}
+
+
+func (i *id3v2) frame(id string) (f *id3v2frame) {
+    for _, f = range i.frames {
+        if f.id == id {
+            return f
+        }
+    }
+    return nil
+}
+
+
+// Returns the minimum offset from the end of this tag to the next tag as
+// given by a SEEK frame, or -1 if there is no SEEK frame.
+func (i *id3v2) seek() (offset int) {
+    f := i.frame("SEEK")
+    if f == nil || len(f.data) < 4 {
+        return -1
+    }
+    return int(binary.BigEndian.Uint32(f.data[0:4]))
+}
+
+
+func (i *id3v2) bytes() []byte {
+    // Note id3v2.2 tags are written as id3v2.4.
+    version := i.version
+    if version < 3 {
+        version = 4
+    }
+
+    body := []byte{}
+    for _, f := range i.frames {
+        body = append(body, f.bytes(version, i.compressthreshold)...)
+    }
+
+    if i.extendedheader != nil {
+        body = append(i.extendedheader.bytes(version, body, i.padding), body...)
+    }
+
+    body = append(body, make([]byte, i.padding)...)
+
+    flags := i.flags &^ (id3v2flagunsynchronisation |
+                         id3v2flagextendedheader |
+                         id3v2flagfooter)
+    if i.extendedheader != nil {
+        flags |= id3v2flagextendedheader
+    }
+    if i.footer && version == 4 {
+        flags |= id3v2flagfooter
+    }
+
+    bytes := []byte("ID3")
+    bytes = append(bytes, version, i.revision, flags)
+    bytes = append(bytes, synchsafebytes(len(body), 4)...)
+    bytes = append(bytes, body...)
+    if flags & id3v2flagfooter != 0 {
+        bytes = append(bytes, "3DI"...)
+        bytes = append(bytes, version, i.revision, flags)
+        bytes = append(bytes, synchsafebytes(len(body), 4)...)
+    }
+
+    return bytes
+}
+
+
+// Replaces the first frame with the given identifier, or appends the frame
+// if there is none.
+func (i *id3v2) setframe(f *id3v2frame) {
+    for j, f1 := range i.frames {
+        if f1.id == f.id {
+            i.frames[j] = f
+            return
+        }
+    }
+    i.frames = append(i.frames, f)
+}
+
+
+func (i *id3v2) removeframes(id string) {
+    frames := []*id3v2frame{}
+    for _, f := range i.frames {
+        if f.id != id {
+            frames = append(frames, f)
+        }
+    }
+    i.frames = frames
+}
+
+
+// Upgrade an id3v2.2 or id3v2.3 tag to id3v2.4:
+// - TYER, TDAT and TIME are combined into TDRC.
+// - TORY becomes TDOR.
+// - IPLS becomes TIPL.
+// - TRDA, TSIZ, RVAD and EQUA, which id3v2.4 drops, are dropped and their ids
+//   returned.
+// Other frames without an id3v2.4 equivalent are kept as they are.
+func (i *id3v2) upgrade() (dropped []string, err error) {
+    if i.version == 4 {
+        return nil, nil
+    }
+
+    var year, date, time string
+    frames := []*id3v2frame{}
+    for _, f := range i.frames {
+        f1 := &id3v2frame{ id: f.id, group: f.group, data: f.data }
+
+        if i.version == 3 {
+            if f.flags & id3v23frameflagencryption != 0 {
+                return nil,
+                       fmt.Errorf("Unable to upgrade encrypted id3v2 frame %s.",
+                                  f.id)
+            }
+
+            // Status flags move down one bit, grouping moves up one bit.
+            f1.flags = f.flags >> 1 & 0x7000
+            if f.flags & id3v23frameflaggrouping != 0 {
+                f1.flags |= id3v24frameflaggrouping
+            }
+        }
+
+        switch f.id {
+            case "TYER":
+                year = f.text()
+                continue
+            case "TDAT":
+                date = f.text()
+                continue
+            case "TIME":
+                time = f.text()
+                continue
+            case "TORY":
+                f1.id = "TDOR"
+            case "IPLS":
+                f1.id = "TIPL"
+            case "TRDA", "TSIZ", "RVAD", "EQUA":
+                dropped = append(dropped, f.id)
+                continue
+        }
+
+        frames = append(frames, f1)
+    }
+
+    i.frames = frames
+    i.version = 4
+    i.revision = 0
+    i.flags &^= id3v2flagunsynchronisation | id3v2flagextendedheader
+
+    if year != "" && i.frame("TDRC") == nil {
+        timestamp := year
+        if len(date) == 4 {
+            timestamp += "-" + date[2:4] + "-" + date[0:2]
+            if len(time) == 4 {
+                timestamp += "T" + time[0:2] + ":" + time[2:4]
+            }
+        }
+        i.setframe(newid3v2textframe("TDRC", timestamp))
+    }
+
+    return dropped, nil
+}
+
+
+// Text encodings are:
+// 0:        ISO-8859-1.
+// 1:        UTF-16 with BOM.
+// 2:        UTF-16BE without BOM, id3v2.4 only.
+// 3:        UTF-8, id3v2.4 only.
+func id3v2textencoding(textencoding byte) (e encoding.Encoding, err error) {
+    switch textencoding {
+        case 0:
+            return charmap.ISO8859_1, nil
+        case 1:
+            return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), nil
+        case 2:
+            return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+        case 3:
+            return unicode.UTF8, nil
+    }
+    return nil, fmt.Errorf("Unrecognised id3v2 text encoding %d.",
+                           textencoding)
+}
+
+
+// Reads a terminated string, returning the string and the remaining bytes.
+// Terminators are $00 for ISO-8859-1 and UTF-8, and $00 $00 for UTF-16.
+func id3v2readstring(textencoding byte,
+                     bytes []byte) (s string, rest []byte, err error) {
+    var e encoding.Encoding
+    if e, err = id3v2textencoding(textencoding); err != nil {
+        return "", nil, err
+    }
+
+    n := len(bytes)
+    width := 0
+    if textencoding == 1 || textencoding == 2 {
+        for j := 0; j + 1 < len(bytes); j += 2 {
+            if bytes[j] == 0 && bytes[j + 1] == 0 {
+                n = j
+                width = 2
+                break
+            }
+        }
+    } else {
+        for j := 0; j < len(bytes); j++ {
+            if bytes[j] == 0 {
+                n = j
+                width = 1
+                break
+            }
+        }
+    }
+
+    // An empty UTF-16 string may omit its BOM.
+    if n == 0 {
+        return "", bytes[width:], nil
+    }
+
+    var b []byte
+    if b, err = e.NewDecoder().Bytes(bytes[0:n]); err != nil {
+        return "", nil, err
+    }
+
+    return string(b), bytes[n + width:], nil
+}
+
+
+func id3v2writestring(textencoding byte, s string) (bytes []byte) {
+    e, err := id3v2textencoding(textencoding)
+    if err != nil {
+        return nil
+    }
+
+    if textencoding == 1 {
+        e = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+    }
+
+    if bytes, err = encoding.ReplaceUnsupported(e.NewEncoder()).Bytes([]byte(s));
+       err != nil {
+        return nil
+    }
+
+    if textencoding == 1 || textencoding == 2 {
+        return append(bytes, 0, 0)
+    }
+    return append(bytes, 0)
+}
+
+
+// Returns the strings of a text frame.  Id3v2.4 permits several strings
+// separated by terminators.
+func (f *id3v2frame) texts() (texts []string) {
+    if len(f.data) < 1 || f.id[0] != 'T' {
+        return nil
+    }
+
+    textencoding := f.data[0]
+    rest := f.data[1:]
+    for len(rest) > 0 {
+        var s string
+        var err error
+        if s, rest, err = id3v2readstring(textencoding, rest); err != nil {
+            return texts
+        }
+        texts = append(texts, s)
+    }
+
+    for len(texts) > 0 && texts[len(texts) - 1] == "" {
+        texts = texts[:len(texts) - 1]
+    }
+
+    return texts
+}
+
+
+// Returns the undecoded strings of an ISO-8859-1 text frame, whose bytes may
+// really be in some other encoding, or nil for other encodings.
+func (f *id3v2frame) latin1texts() (texts []string) {
+    if len(f.data) < 1 || f.id[0] != 'T' || f.data[0] != 0 {
+        return nil
+    }
+
+    texts = strings.Split(string(f.data[1:]), "\x00")
+    for len(texts) > 0 && texts[len(texts) - 1] == "" {
+        texts = texts[:len(texts) - 1]
+    }
+
+    return texts
+}
+
+
+func (f *id3v2frame) text() string {
+    return strings.Join(f.texts(), "/")
+}
+
+
+// Text frames are written as UTF-8.
+func newid3v2textframe(id string, texts ...string) *id3v2frame {
+    data := []byte{ 3 }
+    for j, s := range texts {
+        if j > 0 {
+            data = append(data, 0)
+        }
+        data = append(data, s...)
+    }
+    return &id3v2frame{ id: id, data: data }
+}
+
+
+// Comments are written as UTF-8 with an undetermined language and no
+// description, the same as lyrics.
+func newid3v2commframe(text string) *id3v2frame {
+    data := []byte{ 3 }
+    data = append(data, id3v2lyricslanguage...)
+    data = append(data, 0)
+    data = append(data, text...)
+    return &id3v2frame{ id: "COMM", data: data }
+}
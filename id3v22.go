@@ -0,0 +1,170 @@
+// 'id3v22.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+)
+
+
+// See:  http://id3.org/id3v2-00
+// Id3v2.2 frames have three character identifiers, three byte sizes and no
+// flags.  They are mapped onto their id3v2.3 and id3v2.4 equivalents as they
+// are read.
+var id3v22frameids = map[string]string {
+    "BUF": "RBUF",
+    "CNT": "PCNT",
+    "COM": "COMM",
+    "CRA": "AENC",
+    "ETC": "ETCO",
+    "EQU": "EQUA",
+    "GEO": "GEOB",
+    "IPL": "IPLS",
+    "LNK": "LINK",
+    "MCI": "MCDI",
+    "MLL": "MLLT",
+    "PIC": "APIC",
+    "POP": "POPM",
+    "REV": "RVRB",
+    "RVA": "RVAD",
+    "SLT": "SYLT",
+    "STC": "SYTC",
+    "TAL": "TALB",
+    "TBP": "TBPM",
+    "TCM": "TCOM",
+    "TCO": "TCON",
+    "TCR": "TCOP",
+    "TDA": "TDAT",
+    "TDY": "TDLY",
+    "TEN": "TENC",
+    "TFT": "TFLT",
+    "TIM": "TIME",
+    "TKE": "TKEY",
+    "TLA": "TLAN",
+    "TLE": "TLEN",
+    "TMT": "TMED",
+    "TOA": "TOPE",
+    "TOF": "TOFN",
+    "TOL": "TOLY",
+    "TOR": "TORY",
+    "TOT": "TOAL",
+    "TP1": "TPE1",
+    "TP2": "TPE2",
+    "TP3": "TPE3",
+    "TP4": "TPE4",
+    "TPA": "TPOS",
+    "TPB": "TPUB",
+    "TRC": "TSRC",
+    "TRD": "TRDA",
+    "TRK": "TRCK",
+    "TSI": "TSIZ",
+    "TSS": "TSSE",
+    "TT1": "TIT1",
+    "TT2": "TIT2",
+    "TT3": "TIT3",
+    "TXT": "TEXT",
+    "TXX": "TXXX",
+    "TYE": "TYER",
+    "UFI": "UFID",
+    "ULT": "USLT",
+    "WAF": "WOAF",
+    "WAR": "WOAR",
+    "WAS": "WOAS",
+    "WCM": "WCOM",
+    "WCP": "WCOP",
+    "WPB": "WPUB",
+    "WXX": "WXXX",
+
+    // iTunes extensions.
+    "TCP": "TCMP",
+    "TS2": "TSO2",
+    "TSA": "TSOA",
+    "TSC": "TSOC",
+    "TSP": "TSOP",
+    "TST": "TSOT",
+}
+
+
+// Frames without an equivalent, e.g. CRM, are kept as experimental frames
+// so nothing is lost.
+func id3v22frameid(id string) string {
+    if id1, ok := id3v22frameids[id]; ok {
+        return id1
+    }
+    return "X" + id
+}
+
+
+func newid3v22framesfrombytes(body []byte) (frames []*id3v2frame,
+                                            padding []byte,
+                                            err error) {
+
+    // Frame header is six bytes:
+    // 0..2:     frame identifier.
+    // 3..5:     size.
+
+    for len(body) >= 6 && body[0] != 0 {
+        id := string(body[0:3])
+        n := int(body[3]) << 16 | int(body[4]) << 8 | int(body[5])
+
+        if 6 + n > len(body) {
+            return nil, nil, fmt.Errorf("Unable to parse id3v2.2 frame %s.",
+                                        id)
+        }
+
+        f := &id3v2frame{ id: id3v22frameid(id),
+                          data: body[6:6 + n] }
+
+        switch id {
+            case "PIC":
+                if f.data, err = id3v22pictoapic(f.data); err != nil {
+                    return nil, nil, err
+                }
+            case "LNK":
+                if len(f.data) >= 3 {
+                    f.data = append([]byte(id3v22frameid(string(f.data[0:3]))),
+                                    f.data[3:]...)
+                }
+        }
+
+        frames = append(frames, f)
+        body = body[6 + n:]
+    }
+
+    return frames, body, nil
+}
+
+
+// PIC has a three character image format where APIC has a mime type:
+// 0:        text encoding.
+// 1..3:     image format.
+// 4:        picture type.
+// 5..:      description, terminated.
+// ..:       picture data.
+func id3v22pictoapic(data []byte) (data1 []byte, err error) {
+    if len(data) < 5 {
+        return nil, fmt.Errorf("Unable to parse id3v2.2 PIC frame.")
+    }
+
+    var mimetype string
+    switch format := strings.ToUpper(string(data[1:4])); format {
+        case "JPG":
+            mimetype = "image/jpeg"
+        case "-->":
+            mimetype = "-->"
+        default:
+            mimetype = "image/" + strings.ToLower(format)
+    }
+
+    var buffer bytes.Buffer
+    buffer.WriteByte(data[0])
+    buffer.WriteString(mimetype)
+    buffer.WriteByte(0)
+    buffer.Write(data[4:])
+    return buffer.Bytes(), nil
+}
@@ -0,0 +1,74 @@
+// 'id3v22_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "reflect"
+    "testing"
+)
+
+
+func Test_id3v22upgrade(t *testing.T) {
+    body := []byte("TT2\x00\x00\x06\x00Title" +
+                   "TYE\x00\x00\x05\x001999" +
+                   "TSI\x00\x00\x05\x001234" +
+                   "PIC\x00\x00\x07\x00JPG\x03\x00\xff")
+    bytes := append([]byte("ID3\x02\x00\x00\x00\x00\x00"), byte(len(body)))
+    bytes = append(bytes, body...)
+
+    i, err := newid3v2frombytes(bytes)
+    if ! (err == nil &&
+          i.version == 2 &&
+          i.frame("TIT2").text() == "Title" &&
+          string(i.frame("APIC").data) == "\x00image/jpeg\x00\x03\x00\xff") {
+        t.Errorf("Test_id3v22upgrade:  failed")
+        return
+    }
+
+    if dropped, err := i.upgrade(); ! (err == nil &&
+                                       i.version == 4 &&
+                                       i.frame("TYER") == nil &&
+                                       i.frame("TDRC").text() == "1999" &&
+                                       i.frame("TSIZ") == nil &&
+                                       reflect.DeepEqual(dropped,
+                                                         []string{ "TSIZ" })) {
+        t.Errorf("Test_id3v22upgrade:  failed")
+        return
+    }
+}
+
+
+func Test_id3v22frameid(t *testing.T) {
+    if ! (id3v22frameid("TT2") == "TIT2" &&
+          id3v22frameid("CRM") == "XCRM") {
+        t.Errorf("Test_id3v22frameid:  failed")
+        return
+    }
+}
+
+
+func Test_id3v22framesfrombytes(t *testing.T) {
+    body := []byte("LNK\x00\x00\x07TT2http" +
+                   "PIC\x00\x00\x06\x00PNG\x03\x00" +
+                   "\x00\x00\x00\x00")
+    frames, padding, err := newid3v22framesfrombytes(body)
+    if ! (err == nil &&
+          len(frames) == 2 &&
+          frames[0].id == "LINK" &&
+          string(frames[0].data) == "TIT2http" &&
+          frames[1].id == "APIC" &&
+          string(frames[1].data) == "\x00image/png\x00\x03\x00" &&
+          len(padding) == 4) {
+        t.Errorf("Test_id3v22framesfrombytes:  failed")
+        return
+    }
+
+    // Truncated.
+    if _, _, err = newid3v22framesfrombytes(body[0:12]); err == nil {
+        t.Errorf("Test_id3v22framesfrombytes:  failed")
+        return
+    }
+}
@@ -0,0 +1,105 @@
+// 'id3v2_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+
+func Test_id3v2roundtrip(t *testing.T) {
+    i := &id3v2{ version: 4, padding: 16 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    i.setframe(newid3v2textframe("TPE1", "Artist"))
+
+    i1, err := newid3v2frombytes(i.bytes())
+    if ! (err == nil &&
+          len(i1.frames) == 2 &&
+          i1.frame("TIT2").text() == "Title" &&
+          i1.frame("TPE1").text() == "Artist" &&
+          i1.padding == 16) {
+        t.Errorf("Test_id3v2roundtrip:  failed")
+        return
+    }
+}
+
+
+func Test_id3v2crc(t *testing.T) {
+    i := &id3v2{ version: 4,
+                 padding: 8,
+                 extendedheader: &id3v2extendedheader{ crc: true } }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+
+    bytes := i.bytes()
+    i1, err := newid3v2frombytes(bytes)
+    if ! (err == nil &&
+          i1.extendedheader != nil &&
+          i1.extendedheader.crc &&
+          i1.extendedheader.crc32 == i1.extendedheader.crc32calculated) {
+        t.Errorf("Test_id3v2crc:  failed")
+        return
+    }
+
+    // Corrupt the title.
+    bytes[len(bytes) - 9] ^= 0xff
+    i1, err = newid3v2frombytes(bytes)
+    if ! (err == nil &&
+          i1.extendedheader.crc32 != i1.extendedheader.crc32calculated) {
+        t.Errorf("Test_id3v2crc:  failed")
+        return
+    }
+}
+
+
+func Test_id3v2compression(t *testing.T) {
+    for _, version := range []byte{ 3, 4 } {
+        text := strings.Repeat("Worth compressing.  ", 10)
+        i := &id3v2{ version: version, compressthreshold: 16 }
+        i.setframe(&id3v2frame{ id: "TXXX", data: append([]byte{ 0 }, text...) })
+
+        bytes := i.bytes()
+        i1, err := newid3v2frombytes(bytes)
+        if ! (err == nil &&
+              len(bytes) < 10 + 10 + 1 + len(text) &&
+              i1.frame("TXXX").text() == text) {
+            t.Errorf("Test_id3v2compression:  failed")
+            return
+        }
+    }
+}
+
+
+func Test_id3v2upgrade(t *testing.T) {
+    i := &id3v2{ version: 3 }
+    for _, f := range []*id3v2frame{ newid3v2textframe("TIT2", "Title"),
+                                     newid3v2textframe("TYER", "1999"),
+                                     newid3v2textframe("TDAT", "3112"),
+                                     newid3v2textframe("TIME", "2359"),
+                                     newid3v2textframe("TORY", "1998"),
+                                     newid3v2textframe("TRDA", "31st December"),
+                                     newid3v2textframe("TSIZ", "1234"),
+                                     &id3v2frame{ id: "RVAD", data: []byte{ 0 } },
+                                     &id3v2frame{ id: "EQUA", data: []byte{ 0 } } } {
+        i.frames = append(i.frames, f)
+    }
+
+    dropped, err := i.upgrade()
+    ids := []string{}
+    for _, f := range i.frames {
+        ids = append(ids, f.id)
+    }
+    if ! (err == nil &&
+          i.version == 4 &&
+          reflect.DeepEqual(ids, []string{ "TIT2", "TDOR", "TDRC" }) &&
+          i.frame("TDRC").text() == "1999-12-31T23:59" &&
+          reflect.DeepEqual(dropped,
+                            []string{ "TRDA", "TSIZ", "RVAD", "EQUA" })) {
+        t.Errorf("Test_id3v2upgrade:  failed, %v", ids)
+        return
+    }
+}
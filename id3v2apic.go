@@ -0,0 +1,184 @@
+// 'id3v2apic.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "path"
+    "strings"
+)
+
+
+// See:  http://id3.org/id3v2.4.0-frames#APIC
+type id3v2apic struct {
+    textencoding byte
+    mimetype string
+    picturetype byte
+    description string
+    data []byte
+}
+
+
+const id3v2apicfrontcover = 0x03
+
+
+var id3v2apicpicturetypes = []string {
+    "other",                                                // $00.
+    "file icon",                                            // $01.
+    "other file icon",                                      // $02.
+    "front cover",                                          // $03.
+    "back cover",                                           // $04.
+    "leaflet page",                                         // $05.
+    "media",                                                // $06.
+    "lead artist",                                          // $07.
+    "artist",                                               // $08.
+    "conductor",                                            // $09.
+    "band",                                                 // $0A.
+    "composer",                                             // $0B.
+    "lyricist",                                             // $0C.
+    "recording location",                                   // $0D.
+    "during recording",                                     // $0E.
+    "during performance",                                   // $0F.
+    "screen capture",                                       // $10.
+    "bright coloured fish",                                 // $11.
+    "illustration",                                         // $12.
+    "band logotype",                                        // $13.
+    "publisher logotype",                                   // $14.
+}
+
+
+func id3v2apicpicturetype(picturetype byte) string {
+    if int(picturetype) < len(id3v2apicpicturetypes) {
+        return id3v2apicpicturetypes[picturetype]
+    }
+    return fmt.Sprintf("type %d", picturetype)
+}
+
+
+// APIC is:
+// 0:        text encoding.
+// 1..:      mime type, ISO-8859-1, terminated.
+// ..:       picture type.
+// ..:       description, terminated.
+// ..:       picture data.
+func newid3v2apicfromframe(f *id3v2frame) (a *id3v2apic, err error) {
+    if f.id != "APIC" || len(f.data) < 1 {
+        return nil, fmt.Errorf("Unable to parse id3v2 APIC frame.")
+    }
+
+    a = new(id3v2apic)
+    a.textencoding = f.data[0]
+
+    var rest []byte
+    if a.mimetype, rest, err = id3v2readstring(0, f.data[1:]); err != nil {
+        return nil, err
+    }
+
+    if len(rest) < 1 {
+        return nil, fmt.Errorf("Unable to parse id3v2 APIC frame.")
+    }
+    a.picturetype = rest[0]
+
+    if a.description, rest, err = id3v2readstring(a.textencoding,
+                                                   rest[1:]); err != nil {
+        return nil, err
+    }
+
+    a.data = rest
+    return a, nil
+}
+
+
+func (a *id3v2apic) frame() (f *id3v2frame) {
+    data := []byte{ a.textencoding }
+    data = append(data, id3v2writestring(0, a.mimetype)...)
+    data = append(data, a.picturetype)
+    data = append(data, id3v2writestring(a.textencoding, a.description)...)
+    data = append(data, a.data...)
+    return &id3v2frame{ id: "APIC", data: data }
+}
+
+
+// Replace any APIC frames of the same picture type.
+func (i *id3v2) setapic(a *id3v2apic) {
+    frames := []*id3v2frame{}
+    for _, f := range i.frames {
+        if a1, err := newid3v2apicfromframe(f);
+           err == nil && a1.picturetype == a.picturetype {
+            continue
+        }
+        frames = append(frames, f)
+    }
+    i.frames = append(frames, a.frame())
+}
+
+
+// Detect the image mime type from the image header.
+func imagemimetype(data []byte) string {
+    switch {
+        case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+            return "image/jpeg"
+        case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+            return "image/png"
+        case bytes.HasPrefix(data, []byte("GIF87a")) ||
+             bytes.HasPrefix(data, []byte("GIF89a")):
+            return "image/gif"
+        case bytes.HasPrefix(data, []byte("BM")):
+            return "image/bmp"
+    }
+    return ""
+}
+
+
+var imageextensions = map[string]string {
+    "image/jpeg": ".jpg",
+    "image/png":  ".png",
+    "image/gif":  ".gif",
+    "image/bmp":  ".bmp",
+}
+
+
+// Find a cover image in directorypath, trying each name in order, e.g.
+// 'cover' matches 'cover.jpg', 'Cover.png' and so on.  Returns nil if there
+// is none.
+func findcover(directorypath string,
+               names []string) (a *id3v2apic, filename string, err error) {
+    fileinfos, err := ioutil.ReadDir(directorypath)
+    if err != nil {
+        return nil, "", err
+    }
+
+    for _, name := range names {
+        for _, fileinfo := range fileinfos {
+            extension := path.Ext(fileinfo.Name())
+            if fileinfo.IsDir() ||
+               !strings.EqualFold(strings.TrimSuffix(fileinfo.Name(),
+                                                     extension),
+                                  name) {
+                continue
+            }
+
+            var data []byte
+            filename = path.Join(directorypath, fileinfo.Name())
+            if data, err = ioutil.ReadFile(filename); err != nil {
+                return nil, "", err
+            }
+
+            mimetype := imagemimetype(data)
+            if mimetype == "" {
+                continue
+            }
+
+            return &id3v2apic{ mimetype: mimetype,
+                               picturetype: id3v2apicfrontcover,
+                               data: data }, filename, nil
+        }
+    }
+
+    return nil, "", nil
+}
@@ -0,0 +1,66 @@
+// 'id3v2apic_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io/ioutil"
+    "os"
+    "path"
+    "testing"
+)
+
+
+func Test_imagemimetype(t *testing.T) {
+    if ! (imagemimetype([]byte("\xff\xd8\xff\xe0")) == "image/jpeg" &&
+          imagemimetype([]byte("\x89PNG\r\n\x1a\n")) == "image/png" &&
+          imagemimetype([]byte("GIF89a")) == "image/gif" &&
+          imagemimetype([]byte("BM")) == "image/bmp" &&
+          imagemimetype([]byte("<svg")) == "") {
+        t.Errorf("Test_imagemimetype:  failed")
+        return
+    }
+}
+
+
+func Test_findcover(t *testing.T) {
+    directorypath, err := ioutil.TempDir("", "mp3adora")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(directorypath)
+
+    for name, data := range map[string]string {
+        "Folder.PNG": "\x89PNG\r\n\x1a\n",
+        "front.jpg":  "\xff\xd8\xff\xe0",
+        "cover.txt":  "Not an image",
+    } {
+        err = ioutil.WriteFile(path.Join(directorypath, name),
+                               []byte(data),
+                               0644)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    // Names are tried in order, ignoring case and skipping files which are
+    // not images.
+    a, filename, err := findcover(directorypath,
+                                  []string{ "cover", "folder", "front" })
+    if ! (err == nil &&
+          a != nil &&
+          a.mimetype == "image/png" &&
+          a.picturetype == id3v2apicfrontcover &&
+          path.Base(filename) == "Folder.PNG") {
+        t.Errorf("Test_findcover:  failed")
+        return
+    }
+
+    a, _, err = findcover(directorypath, []string{ "back" })
+    if ! (err == nil && a == nil) {
+        t.Errorf("Test_findcover:  failed")
+        return
+    }
+}
@@ -0,0 +1,302 @@
+// 'id3v2chapters.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bufio"
+    "encoding/binary"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+
+// See:  http://id3.org/id3v2-chapters-1.0
+type id3v2chap struct {
+    elementid string
+    starttime int
+    endtime int
+    startoffset uint32
+    endoffset uint32
+    frames []*id3v2frame
+}
+
+
+type id3v2ctoc struct {
+    elementid string
+    toplevel bool
+    ordered bool
+    childelementids []string
+    frames []*id3v2frame
+}
+
+
+// Offsets of $FFFFFFFF mean times should be used instead.
+const id3v2chapnooffset = 0xffffffff
+
+
+// CHAP is:
+// 0..:      element id, ISO-8859-1, terminated.
+// ..:       start time, milliseconds.
+// ..:       end time, milliseconds.
+// ..:       start offset, bytes from the beginning of the file.
+// ..:       end offset, bytes from the beginning of the file.
+// ..:       subframes.
+func newid3v2chapfromframe(version byte,
+                           f *id3v2frame) (c *id3v2chap, err error) {
+    if f.id != "CHAP" {
+        return nil, fmt.Errorf("Unable to parse id3v2 CHAP frame.")
+    }
+
+    c = new(id3v2chap)
+
+    var rest []byte
+    if c.elementid, rest, err = id3v2readstring(0, f.data); err != nil {
+        return nil, err
+    }
+
+    if len(rest) < 16 {
+        return nil, fmt.Errorf("Unable to parse id3v2 CHAP frame.")
+    }
+    c.starttime = int(binary.BigEndian.Uint32(rest[0:4]))
+    c.endtime = int(binary.BigEndian.Uint32(rest[4:8]))
+    c.startoffset = binary.BigEndian.Uint32(rest[8:12])
+    c.endoffset = binary.BigEndian.Uint32(rest[12:16])
+
+    if c.frames, _, err = newid3v2framesfrombytes(version,
+                                                  false,
+                                                  rest[16:]); err != nil {
+        return nil, err
+    }
+
+    return c, nil
+}
+
+
+func (c *id3v2chap) frame(version byte) (f *id3v2frame) {
+    data := id3v2writestring(0, c.elementid)
+    bytes16 := make([]byte, 16)
+    binary.BigEndian.PutUint32(bytes16[0:4], uint32(c.starttime))
+    binary.BigEndian.PutUint32(bytes16[4:8], uint32(c.endtime))
+    binary.BigEndian.PutUint32(bytes16[8:12], c.startoffset)
+    binary.BigEndian.PutUint32(bytes16[12:16], c.endoffset)
+    data = append(data, bytes16...)
+    for _, f1 := range c.frames {
+        data = append(data, f1.bytes(version, 0)...)
+    }
+    return &id3v2frame{ id: "CHAP", data: data }
+}
+
+
+// CTOC is:
+// 0..:      element id, ISO-8859-1, terminated.
+// ..:       flags, %000000ab, a top-level, b ordered.
+// ..:       entry count.
+// ..:       child element ids, ISO-8859-1, terminated.
+// ..:       subframes.
+func newid3v2ctocfromframe(version byte,
+                           f *id3v2frame) (c *id3v2ctoc, err error) {
+    if f.id != "CTOC" {
+        return nil, fmt.Errorf("Unable to parse id3v2 CTOC frame.")
+    }
+
+    c = new(id3v2ctoc)
+
+    var rest []byte
+    if c.elementid, rest, err = id3v2readstring(0, f.data); err != nil {
+        return nil, err
+    }
+
+    if len(rest) < 2 {
+        return nil, fmt.Errorf("Unable to parse id3v2 CTOC frame.")
+    }
+    c.toplevel = rest[0] & 0x02 != 0
+    c.ordered = rest[0] & 0x01 != 0
+    count := int(rest[1])
+    rest = rest[2:]
+
+    for j := 0; j < count; j++ {
+        var elementid string
+        if elementid, rest, err = id3v2readstring(0, rest); err != nil {
+            return nil, err
+        }
+        c.childelementids = append(c.childelementids, elementid)
+    }
+
+    if c.frames, _, err = newid3v2framesfrombytes(version,
+                                                  false,
+                                                  rest); err != nil {
+        return nil, err
+    }
+
+    return c, nil
+}
+
+
+func (c *id3v2ctoc) frame(version byte) (f *id3v2frame) {
+    data := id3v2writestring(0, c.elementid)
+
+    var flags byte
+    if c.toplevel {
+        flags |= 0x02
+    }
+    if c.ordered {
+        flags |= 0x01
+    }
+    data = append(data, flags, byte(len(c.childelementids)))
+
+    for _, elementid := range c.childelementids {
+        data = append(data, id3v2writestring(0, elementid)...)
+    }
+    for _, f1 := range c.frames {
+        data = append(data, f1.bytes(version, 0)...)
+    }
+    return &id3v2frame{ id: "CTOC", data: data }
+}
+
+
+// Returns the title from a chapter's TIT2 subframe.
+func id3v2chaptertitle(frames []*id3v2frame) string {
+    for _, f := range frames {
+        if f.id == "TIT2" {
+            return f.text()
+        }
+    }
+    return ""
+}
+
+
+type chapter struct {
+    time int
+    title string
+}
+
+
+// Parse either a cue sheet or lines of 'HH:MM:SS title', where seconds may
+// have a fraction and hours may be omitted.  Times are in milliseconds.
+func parsechapters(text string) (chapters []chapter, err error) {
+    regexpcuetrack := regexp.MustCompile(`^\s*TRACK\s+[0-9]+`)
+
+    scanner := bufio.NewScanner(strings.NewReader(text))
+    for scanner.Scan() {
+        if regexpcuetrack.MatchString(scanner.Text()) {
+            return parsecuesheet(text)
+        }
+    }
+
+    regexpline :=
+        regexp.MustCompile(`^\s*(?:([0-9]+):)?([0-9]+):([0-9]+(?:\.[0-9]+)?)\s+(.*)$`)
+
+    scanner = bufio.NewScanner(strings.NewReader(text))
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        result := regexpline.FindStringSubmatch(line)
+        if result == nil {
+            return nil, fmt.Errorf("Unable to parse chapter %s", line)
+        }
+
+        hours, _ := strconv.Atoi(result[1])
+        minutes, _ := strconv.Atoi(result[2])
+        seconds, _ := strconv.ParseFloat(result[3], 64)
+        chapters = append(chapters,
+                          chapter{ time: (hours * 3600 + minutes * 60) * 1000 +
+                                         int(seconds * 1000 + 0.5),
+                                   title: result[4] })
+    }
+    if err = scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    return chapters, nil
+}
+
+
+// See:  https://en.wikipedia.org/wiki/Cue_sheet_(computing)
+// Each TRACK's TITLE and INDEX 01 'mm:ss:ff', with 75 frames per second.
+func parsecuesheet(text string) (chapters []chapter, err error) {
+    regexptrack := regexp.MustCompile(`^\s*TRACK\s+([0-9]+)`)
+    regexptitle := regexp.MustCompile(`^\s*TITLE\s+"?([^"]*)"?`)
+    regexpindex :=
+        regexp.MustCompile(`^\s*INDEX\s+01\s+([0-9]+):([0-9]+):([0-9]+)`)
+
+    var current *chapter
+    scanner := bufio.NewScanner(strings.NewReader(text))
+    for scanner.Scan() {
+        line := scanner.Text()
+
+        if result := regexptrack.FindStringSubmatch(line); result != nil {
+            chapters = append(chapters,
+                              chapter{ title: "Track " + result[1] })
+            current = &chapters[len(chapters) - 1]
+            continue
+        }
+
+        if current == nil {
+            continue
+        }
+
+        if result := regexptitle.FindStringSubmatch(line); result != nil {
+            current.title = result[1]
+            continue
+        }
+
+        if result := regexpindex.FindStringSubmatch(line); result != nil {
+            minutes, _ := strconv.Atoi(result[1])
+            seconds, _ := strconv.Atoi(result[2])
+            frames, _ := strconv.Atoi(result[3])
+            current.time = (minutes * 60 + seconds) * 1000 + frames * 1000 / 75
+        }
+    }
+    if err = scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    return chapters, nil
+}
+
+
+// Replace any CHAP and CTOC frames with one chapter per entry in chapters,
+// in a single top-level table of contents.  The start and end offsets are
+// filled in by offsets, given the chapter's start and end times.
+func (i *id3v2) setchapters(chapters []chapter,
+                            duration int,
+                            offsets func(starttime int,
+                                         endtime int) (startoffset uint32,
+                                                       endoffset uint32)) {
+    i.removeframes("CHAP")
+    i.removeframes("CTOC")
+
+    ctoc := &id3v2ctoc{ elementid: "toc",
+                        toplevel: true,
+                        ordered: true }
+    chaps := []*id3v2frame{}
+
+    for j, c := range chapters {
+        endtime := duration
+        if j + 1 < len(chapters) {
+            endtime = chapters[j + 1].time
+        }
+
+        chap := &id3v2chap{ elementid: fmt.Sprintf("chp%d", j),
+                            starttime: c.time,
+                            endtime: endtime,
+                            frames: []*id3v2frame{
+                                newid3v2textframe("TIT2", c.title),
+                            } }
+        chap.startoffset, chap.endoffset = offsets(c.time, endtime)
+
+        ctoc.childelementids = append(ctoc.childelementids, chap.elementid)
+        chaps = append(chaps, chap.frame(4))
+    }
+
+    i.frames = append(i.frames, ctoc.frame(4))
+    i.frames = append(i.frames, chaps...)
+}
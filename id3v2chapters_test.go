@@ -0,0 +1,120 @@
+// 'id3v2chapters_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "reflect"
+    "testing"
+)
+
+
+func Test_parsechapters(t *testing.T) {
+    chapters, err := parsechapters("00:00:00 Intro\n" +
+                                   "1:02:03.5 Later\n")
+    if ! (err == nil &&
+          len(chapters) == 2 &&
+          chapters[0] == chapter{ time: 0, title: "Intro" } &&
+          chapters[1] == chapter{ time: 3723500, title: "Later" }) {
+        t.Errorf("Test_parsechapters:  failed")
+        return
+    }
+
+    chapters, err = parsechapters("FILE \"x.mp3\" MP3\n" +
+                                  "  TRACK 01 AUDIO\n" +
+                                  "    TITLE \"One\"\n" +
+                                  "    INDEX 01 00:00:00\n" +
+                                  "  TRACK 02 AUDIO\n" +
+                                  "    TITLE \"Two\"\n" +
+                                  "    INDEX 01 01:02:15\n")
+    if ! (err == nil &&
+          len(chapters) == 2 &&
+          chapters[1] == chapter{ time: 62200, title: "Two" }) {
+        t.Errorf("Test_parsechapters:  failed")
+        return
+    }
+}
+
+// Cue sheet times are 'mm:ss:ff', with 75 frames per second.
+func Test_parsecuesheet(t *testing.T) {
+    chapters, err := parsecuesheet("TITLE \"Album\"\n" +
+                                   "FILE \"x.mp3\" MP3\n" +
+                                   "  TRACK 01 AUDIO\n" +
+                                   "    TITLE \"One\"\n" +
+                                   "    INDEX 00 00:00:00\n" +
+                                   "    INDEX 01 00:00:74\n" +
+                                   "  TRACK 02 AUDIO\n" +
+                                   "    INDEX 01 90:01:30\n")
+    if ! (err == nil &&
+          reflect.DeepEqual(chapters,
+                            []chapter{ { time: 986, title: "One" },
+                                       { time: 5401400, title: "Track 02" } })) {
+        t.Errorf("Test_parsecuesheet:  failed, %v", chapters)
+        return
+    }
+}
+
+
+func Test_setchapters(t *testing.T) {
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    i.setframe(&id3v2frame{ id: "CHAP", data: []byte("old\x00") })
+
+    chapters := []chapter{ { time: 0, title: "One" },
+                           { time: 1500, title: "Two" },
+                           { time: 4000, title: "Three" } }
+    i.setchapters(chapters,
+                  6000,
+                  func(starttime int, endtime int) (uint32, uint32) {
+                      return uint32(starttime * 10), uint32(endtime * 10)
+                  })
+
+    i1, err := newid3v2frombytes(i.bytes())
+    if err != nil {
+        t.Errorf("Test_setchapters:  failed")
+        return
+    }
+
+    ctoc, err := newid3v2ctocfromframe(4, i1.frame("CTOC"))
+    if ! (err == nil &&
+          ctoc.elementid == "toc" &&
+          ctoc.toplevel &&
+          ctoc.ordered &&
+          reflect.DeepEqual(ctoc.childelementids,
+                            []string{ "chp0", "chp1", "chp2" })) {
+        t.Errorf("Test_setchapters:  failed")
+        return
+    }
+
+    chaps := []*id3v2chap{}
+    for _, f := range i1.frames {
+        if f.id != "CHAP" {
+            continue
+        }
+        c, err := newid3v2chapfromframe(4, f)
+        if err != nil {
+            t.Errorf("Test_setchapters:  failed")
+            return
+        }
+        chaps = append(chaps, c)
+    }
+
+    endtimes := []int{ 1500, 4000, 6000 }
+    if len(chaps) != 3 {
+        t.Errorf("Test_setchapters:  failed")
+        return
+    }
+    for j, c := range chaps {
+        if ! (c.elementid == ctoc.childelementids[j] &&
+              c.starttime == chapters[j].time &&
+              c.endtime == endtimes[j] &&
+              c.startoffset == uint32(chapters[j].time * 10) &&
+              c.endoffset == uint32(endtimes[j] * 10) &&
+              id3v2chaptertitle(c.frames) == chapters[j].title) {
+            t.Errorf("Test_setchapters:  failed, %d", j)
+            return
+        }
+    }
+}
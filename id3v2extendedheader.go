@@ -0,0 +1,217 @@
+// 'id3v2extendedheader.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "encoding/binary"
+    "fmt"
+    "hash/crc32"
+)
+
+
+// See:  http://id3.org/id3v2.3.0#ID3v2_extended_header
+//       http://id3.org/id3v2.4.0-structure
+// The update flag and restrictions are id3v2.4 only, the padding size is
+// id3v2.3 only.
+type id3v2extendedheader struct {
+    update bool
+    crc bool
+    crc32 uint32
+    crc32calculated uint32
+    restrictions bool
+    restrictionstagsize int
+    restrictionstextencoding int
+    restrictionstextsize int
+    restrictionsimageencoding int
+    restrictionsimagesize int
+    paddingsize int
+}
+
+
+var id3v2restrictionstagsizes = []string {
+    "128 frames and 1 MB",                                  // 00.
+    "64 frames and 128 KB",                                 // 01.
+    "32 frames and 40 KB",                                  // 10.
+    "32 frames and 4 KB",                                   // 11.
+}
+
+
+var id3v2restrictionstextencodings = []string {
+    "none",                                                 // 0.
+    "ISO-8859-1 or UTF-8",                                  // 1.
+}
+
+
+var id3v2restrictionstextsizes = []string {
+    "none",                                                 // 00.
+    "1024 characters",                                      // 01.
+    "128 characters",                                       // 10.
+    "30 characters",                                        // 11.
+}
+
+
+var id3v2restrictionsimageencodings = []string {
+    "none",                                                 // 0.
+    "PNG or JPEG",                                          // 1.
+}
+
+
+var id3v2restrictionsimagesizes = []string {
+    "none",                                                 // 00.
+    "256x256 pixels or less",                               // 01.
+    "64x64 pixels or less",                                 // 10.
+    "64x64 pixels exactly",                                 // 11.
+}
+
+
+// Returns the extended header and its size in bytes.
+func newid3v2extendedheaderfrombytes(version byte,
+                                     bytes []byte) (e *id3v2extendedheader,
+                                                    size int,
+                                                    err error) {
+    e = new(id3v2extendedheader)
+
+    if version == 3 {
+
+        // Id3v2.3 extended header is:
+        // 0..3:     size, excluding these four bytes.
+        // 4..5:     flags.
+        // 6..9:     padding size.
+        // 10..13:   crc, if flagged.
+
+        if len(bytes) < 10 {
+            return nil, 0, fmt.Errorf("Unable to parse id3v2 extended header.")
+        }
+
+        size = int(binary.BigEndian.Uint32(bytes[0:4])) + 4
+        flags := binary.BigEndian.Uint16(bytes[4:6])
+        e.paddingsize = int(binary.BigEndian.Uint32(bytes[6:10]))
+        e.crc = flags & 0x8000 != 0
+
+        if size > len(bytes) || (e.crc && (size < 14 || len(bytes) < 14)) {
+            return nil, 0, fmt.Errorf("Unable to parse id3v2 extended header.")
+        }
+
+        if e.crc {
+            e.crc32 = binary.BigEndian.Uint32(bytes[10:14])
+        }
+
+        return e, size, nil
+    }
+
+    // Id3v2.4 extended header is:
+    // 0..3:     size, synchsafe, including these four bytes.
+    // 4:        number of flag bytes, always 1.
+    // 5:        flags.
+    // 6..:      data for each flag set, each prefixed with its length.
+
+    if len(bytes) < 6 {
+        return nil, 0, fmt.Errorf("Unable to parse id3v2 extended header.")
+    }
+
+    size = synchsafeint(bytes[0:4])
+    if size < 6 || size > len(bytes) || bytes[4] != 1 {
+        return nil, 0, fmt.Errorf("Unable to parse id3v2 extended header.")
+    }
+
+    flags := bytes[5]
+    e.update = flags & 0x40 != 0
+    e.crc = flags & 0x20 != 0
+    e.restrictions = flags & 0x10 != 0
+
+    data := bytes[6:size]
+    next := func(n int) (d []byte, err error) {
+        if len(data) < 1 || int(data[0]) != n || len(data) < 1 + n {
+            return nil, fmt.Errorf("Unable to parse id3v2 extended header.")
+        }
+        d = data[1:1 + n]
+        data = data[1 + n:]
+        return d, nil
+    }
+
+    if e.update {
+        if _, err = next(0); err != nil {
+            return nil, 0, err
+        }
+    }
+
+    if e.crc {
+        var d []byte
+        if d, err = next(5); err != nil {
+            return nil, 0, err
+        }
+        e.crc32 = uint32(synchsafeint(d))
+    }
+
+    if e.restrictions {
+        var d []byte
+        if d, err = next(1); err != nil {
+            return nil, 0, err
+        }
+
+        // Restrictions are %ppqrrstt.
+        e.restrictionstagsize = int(d[0] >> 6 & 0x03)
+        e.restrictionstextencoding = int(d[0] >> 5 & 0x01)
+        e.restrictionstextsize = int(d[0] >> 3 & 0x03)
+        e.restrictionsimageencoding = int(d[0] >> 2 & 0x01)
+        e.restrictionsimagesize = int(d[0] & 0x03)
+    }
+
+    return e, size, nil
+}
+
+
+// The crc is calculated over the frames for id3v2.3, and over the frames
+// and padding for id3v2.4.
+func (e *id3v2extendedheader) bytes(version byte,
+                                    frames []byte,
+                                    padding int) []byte {
+    if version == 3 {
+        bytes := make([]byte, 10)
+        binary.BigEndian.PutUint32(bytes[0:4], 6)
+        binary.BigEndian.PutUint32(bytes[6:10], uint32(padding))
+        if e.crc {
+            binary.BigEndian.PutUint32(bytes[0:4], 10)
+            binary.BigEndian.PutUint16(bytes[4:6], 0x8000)
+            crc := make([]byte, 4)
+            binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(frames))
+            bytes = append(bytes, crc...)
+        }
+        return bytes
+    }
+
+    var flags byte
+    data := []byte{}
+
+    if e.update {
+        flags |= 0x40
+        data = append(data, 0)
+    }
+
+    if e.crc {
+        flags |= 0x20
+        crc := crc32.NewIEEE()
+        crc.Write(frames)
+        crc.Write(make([]byte, padding))
+        data = append(data, 5)
+        data = append(data, synchsafebytes(int(crc.Sum32()), 5)...)
+    }
+
+    if e.restrictions {
+        flags |= 0x10
+        data = append(data,
+                      1,
+                      byte(e.restrictionstagsize << 6 |
+                           e.restrictionstextencoding << 5 |
+                           e.restrictionstextsize << 3 |
+                           e.restrictionsimageencoding << 2 |
+                           e.restrictionsimagesize))
+    }
+
+    bytes := synchsafebytes(6 + len(data), 4)
+    bytes = append(bytes, 1, flags)
+    return append(bytes, data...)
+}
@@ -0,0 +1,171 @@
+// 'id3v2frame.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "compress/zlib"
+    "encoding/binary"
+    "fmt"
+    "io/ioutil"
+)
+
+
+// Undo the frame format flags so that f.data is the frame content:
+// - id3v2.3 data is preceded by the decompressed size if compressed, the
+//   encryption method if encrypted and the group if grouped.
+// - id3v2.4 data is preceded by the group if grouped, the encryption method if
+//   encrypted and the data length indicator if flagged, and may be
+//   unsynchronised.
+// Encrypted frames are left as they are.  Afterwards only the status flags
+// and grouping flag remain.
+func (f *id3v2frame) decode(version byte, unsynchronisation bool) (err error) {
+    data := f.data
+
+    if version == 3 {
+        if f.flags & id3v23frameflagencryption != 0 {
+            return nil
+        }
+
+        compressed := f.flags & id3v23frameflagcompression != 0
+        if compressed {
+            if len(data) < 4 {
+                return fmt.Errorf("Unable to parse id3v2 frame %s.", f.id)
+            }
+            data = data[4:]
+        }
+
+        if f.flags & id3v23frameflaggrouping != 0 {
+            if len(data) < 1 {
+                return fmt.Errorf("Unable to parse id3v2 frame %s.", f.id)
+            }
+            f.group = data[0]
+            data = data[1:]
+        }
+
+        if compressed {
+            if data, err = inflate(data); err != nil {
+                return fmt.Errorf("Unable to decompress id3v2 frame %s.", f.id)
+            }
+        }
+
+        f.flags &^= id3v23frameflagcompression
+        f.data = data
+        return nil
+    }
+
+    if f.flags & id3v24frameflagencryption != 0 {
+        return nil
+    }
+
+    if f.flags & id3v24frameflaggrouping != 0 {
+        if len(data) < 1 {
+            return fmt.Errorf("Unable to parse id3v2 frame %s.", f.id)
+        }
+        f.group = data[0]
+        data = data[1:]
+    }
+
+    if f.flags & id3v24frameflagdatalengthindicator != 0 {
+        if len(data) < 4 {
+            return fmt.Errorf("Unable to parse id3v2 frame %s.", f.id)
+        }
+        data = data[4:]
+    }
+
+    if unsynchronisation ||
+       f.flags & id3v24frameflagunsynchronisation != 0 {
+        data = resynchronise(data)
+    }
+
+    if f.flags & id3v24frameflagcompression != 0 {
+        if data, err = inflate(data); err != nil {
+            return fmt.Errorf("Unable to decompress id3v2 frame %s.", f.id)
+        }
+    }
+
+    f.flags &^= id3v24frameflagcompression |
+                id3v24frameflagunsynchronisation |
+                id3v24frameflagdatalengthindicator
+    f.data = data
+    return nil
+}
+
+
+// Frames larger than compressthreshold bytes are compressed, a
+// compressthreshold of zero disables compression.
+func (f *id3v2frame) bytes(version byte, compressthreshold int) []byte {
+    flags := f.flags
+    data := f.data
+
+    encrypted := (version == 3 && flags & id3v23frameflagencryption != 0) ||
+                 (version == 4 && flags & id3v24frameflagencryption != 0)
+
+    if !encrypted {
+        grouped := (version == 3 && flags & id3v23frameflaggrouping != 0) ||
+                   (version == 4 && flags & id3v24frameflaggrouping != 0)
+
+        compress := compressthreshold > 0 && len(f.data) > compressthreshold
+
+        prefix := []byte{}
+        if version == 3 {
+            if compress {
+                flags |= id3v23frameflagcompression
+                size := make([]byte, 4)
+                binary.BigEndian.PutUint32(size, uint32(len(f.data)))
+                prefix = append(prefix, size...)
+            }
+            if grouped {
+                prefix = append(prefix, f.group)
+            }
+        } else {
+            if grouped {
+                prefix = append(prefix, f.group)
+            }
+            if compress {
+                flags |= id3v24frameflagcompression |
+                         id3v24frameflagdatalengthindicator
+                prefix = append(prefix, synchsafebytes(len(f.data), 4)...)
+            }
+        }
+
+        if compress {
+            data = deflate(f.data)
+        }
+        data = append(prefix, data...)
+    }
+
+    bytes := []byte(f.id)
+    if version == 3 {
+        size := make([]byte, 4)
+        binary.BigEndian.PutUint32(size, uint32(len(data)))
+        bytes = append(bytes, size...)
+    } else {
+        bytes = append(bytes, synchsafebytes(len(data), 4)...)
+    }
+    bytes = append(bytes, byte(flags >> 8), byte(flags))
+    return append(bytes, data...)
+}
+
+
+func inflate(data []byte) (data1 []byte, err error) {
+    reader, err := zlib.NewReader(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    defer reader.Close()
+
+    return ioutil.ReadAll(reader)
+}
+
+
+func deflate(data []byte) (data1 []byte) {
+    var buffer bytes.Buffer
+    writer := zlib.NewWriter(&buffer)
+    writer.Write(data)
+    writer.Close()
+    return buffer.Bytes()
+}
@@ -0,0 +1,271 @@
+// 'id3v2lyrics.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bufio"
+    "encoding/binary"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+
+// See:  http://id3.org/id3v2.4.0-frames#USLT
+type id3v2uslt struct {
+    textencoding byte
+    language string
+    description string
+    text string
+}
+
+
+// See:  http://id3.org/id3v2.4.0-frames#SYLT
+type id3v2syltline struct {
+    text string
+    timestamp int
+}
+
+
+type id3v2sylt struct {
+    textencoding byte
+    language string
+    timestampformat byte
+    contenttype byte
+    description string
+    lines []id3v2syltline
+}
+
+
+const id3v2sylttimestampmpegframes = 0x01
+const id3v2sylttimestampmilliseconds = 0x02
+
+const id3v2syltcontenttypelyrics = 0x01
+
+
+// Lyrics are written in UTF-8 with an undetermined language.
+const id3v2lyricslanguage = "XXX"
+
+
+// USLT is:
+// 0:        text encoding.
+// 1..3:     language.
+// 4..:      content descriptor, terminated.
+// ..:       lyrics.
+func newid3v2usltfromframe(f *id3v2frame) (u *id3v2uslt, err error) {
+    if f.id != "USLT" || len(f.data) < 4 {
+        return nil, fmt.Errorf("Unable to parse id3v2 USLT frame.")
+    }
+
+    u = new(id3v2uslt)
+    u.textencoding = f.data[0]
+    u.language = string(f.data[1:4])
+
+    var rest []byte
+    if u.description, rest, err = id3v2readstring(u.textencoding,
+                                                   f.data[4:]); err != nil {
+        return nil, err
+    }
+
+    if u.text, _, err = id3v2readstring(u.textencoding, rest); err != nil {
+        return nil, err
+    }
+
+    return u, nil
+}
+
+
+func (u *id3v2uslt) frame() (f *id3v2frame) {
+    data := []byte{ u.textencoding }
+    data = append(data, u.language...)
+    data = append(data, id3v2writestring(u.textencoding, u.description)...)
+    data = append(data, id3v2writestring(u.textencoding, u.text)...)
+    return &id3v2frame{ id: "USLT", data: data }
+}
+
+
+// SYLT is:
+// 0:        text encoding.
+// 1..3:     language.
+// 4:        timestamp format.
+// 5:        content type.
+// 6..:      content descriptor, terminated.
+// ..:       text, terminated, followed by four byte timestamp, repeated.
+func newid3v2syltfromframe(f *id3v2frame) (s *id3v2sylt, err error) {
+    if f.id != "SYLT" || len(f.data) < 6 {
+        return nil, fmt.Errorf("Unable to parse id3v2 SYLT frame.")
+    }
+
+    s = new(id3v2sylt)
+    s.textencoding = f.data[0]
+    s.language = string(f.data[1:4])
+    s.timestampformat = f.data[4]
+    s.contenttype = f.data[5]
+
+    var rest []byte
+    if s.description, rest, err = id3v2readstring(s.textencoding,
+                                                   f.data[6:]); err != nil {
+        return nil, err
+    }
+
+    for len(rest) > 0 {
+        var line id3v2syltline
+        if line.text, rest, err = id3v2readstring(s.textencoding,
+                                                  rest); err != nil {
+            return nil, err
+        }
+        if len(rest) < 4 {
+            return nil, fmt.Errorf("Unable to parse id3v2 SYLT frame.")
+        }
+        line.timestamp = int(binary.BigEndian.Uint32(rest[0:4]))
+        rest = rest[4:]
+        s.lines = append(s.lines, line)
+    }
+
+    return s, nil
+}
+
+
+func (s *id3v2sylt) frame() (f *id3v2frame) {
+    data := []byte{ s.textencoding }
+    data = append(data, s.language...)
+    data = append(data, s.timestampformat, s.contenttype)
+    data = append(data, id3v2writestring(s.textencoding, s.description)...)
+    for _, line := range s.lines {
+        data = append(data, id3v2writestring(s.textencoding, line.text)...)
+        timestamp := make([]byte, 4)
+        binary.BigEndian.PutUint32(timestamp, uint32(line.timestamp))
+        data = append(data, timestamp...)
+    }
+    return &id3v2frame{ id: "SYLT", data: data }
+}
+
+
+// See:  https://en.wikipedia.org/wiki/LRC_(file_format)
+// Lines are '[mm:ss.xx]text', possibly with several timestamps.  Id tags
+// other than offset are ignored.  Returns the lines in time order.
+func parselrc(lrc string) (lines []id3v2syltline, err error) {
+    regexptimestamp :=
+        regexp.MustCompile(`^\[([0-9]+):([0-9]+)(?:[.:]([0-9]+))?\]`)
+    regexpoffset := regexp.MustCompile(`^\[offset:\s*([+-]?[0-9]+)\]`)
+
+    offset := 0
+    scanner := bufio.NewScanner(strings.NewReader(lrc))
+    for scanner.Scan() {
+        line := strings.TrimRight(scanner.Text(), "\r")
+
+        if result := regexpoffset.FindStringSubmatch(line); result != nil {
+            offset, _ = strconv.Atoi(result[1])
+            continue
+        }
+
+        timestamps := []int{}
+        for {
+            result := regexptimestamp.FindStringSubmatch(line)
+            if result == nil {
+                break
+            }
+
+            minutes, _ := strconv.Atoi(result[1])
+            seconds, _ := strconv.Atoi(result[2])
+            fraction := 0
+            if result[3] != "" {
+                // Fractions are hundredths or thousandths.
+                fraction, _ = strconv.Atoi((result[3] + "00")[0:3])
+            }
+
+            timestamps = append(timestamps,
+                                (minutes * 60 + seconds) * 1000 + fraction)
+            line = line[len(result[0]):]
+        }
+
+        for _, timestamp := range timestamps {
+            lines = append(lines, id3v2syltline{ text: line,
+                                                 timestamp: timestamp })
+        }
+    }
+    if err = scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    // Insertion sort, keeping lines with equal timestamps in order.
+    for j := 1; j < len(lines); j++ {
+        for k := j; k > 0 && lines[k].timestamp < lines[k - 1].timestamp; k-- {
+            lines[k], lines[k - 1] = lines[k - 1], lines[k]
+        }
+    }
+
+    // A positive offset makes lyrics appear sooner.
+    for j := range lines {
+        lines[j].timestamp -= offset
+        if lines[j].timestamp < 0 {
+            lines[j].timestamp = 0
+        }
+    }
+
+    return lines, nil
+}
+
+
+func formatlrctimestamp(timestamp int) string {
+    return fmt.Sprintf("[%02d:%02d.%02d]",
+                       timestamp / 60000,
+                       timestamp / 1000 % 60,
+                       timestamp % 1000 / 10)
+}
+
+
+func formatlrc(lines []id3v2syltline) string {
+    var builder strings.Builder
+    for _, line := range lines {
+        builder.WriteString(formatlrctimestamp(line.timestamp))
+        builder.WriteString(line.text)
+        builder.WriteString("\n")
+    }
+    return builder.String()
+}
+
+
+// The unsynchronised text of synchronised lyrics.
+func lyricstext(lines []id3v2syltline) string {
+    texts := []string{}
+    for _, line := range lines {
+        texts = append(texts, line.text)
+    }
+    return strings.Join(texts, "\n")
+}
+
+
+// Replace any USLT and SYLT frames with lyrics from an '.lrc' or '.txt' file.
+func (i *id3v2) setlyrics(lrc bool, lyrics string) (err error) {
+    i.removeframes("USLT")
+    i.removeframes("SYLT")
+
+    if !lrc {
+        u := &id3v2uslt{ textencoding: 3,
+                         language: id3v2lyricslanguage,
+                         text: strings.TrimRight(lyrics, "\n") }
+        i.frames = append(i.frames, u.frame())
+        return nil
+    }
+
+    var lines []id3v2syltline
+    if lines, err = parselrc(lyrics); err != nil {
+        return err
+    }
+
+    u := &id3v2uslt{ textencoding: 3,
+                     language: id3v2lyricslanguage,
+                     text: lyricstext(lines) }
+    s := &id3v2sylt{ textencoding: 3,
+                     language: id3v2lyricslanguage,
+                     timestampformat: id3v2sylttimestampmilliseconds,
+                     contenttype: id3v2syltcontenttypelyrics,
+                     lines: lines }
+    i.frames = append(i.frames, u.frame(), s.frame())
+    return nil
+}
@@ -0,0 +1,154 @@
+// 'id3v2lyrics_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io/ioutil"
+    "os"
+    "reflect"
+    "testing"
+)
+
+
+func Test_parselrc(t *testing.T) {
+    lines, err := parselrc("[ar:Artist]\n" +
+                           "[offset:+500]\n" +
+                           "[00:01.50]One\n" +
+                           "[00:03.00][00:10.125]Chorus\n" +
+                           "[00:05]Two\n")
+    if ! (err == nil &&
+          len(lines) == 4 &&
+          lines[0].text == "One" && lines[0].timestamp == 1000 &&
+          lines[1].text == "Chorus" && lines[1].timestamp == 2500 &&
+          lines[2].text == "Two" && lines[2].timestamp == 4500 &&
+          lines[3].text == "Chorus" && lines[3].timestamp == 9625) {
+        t.Errorf("Test_parselrc:  failed")
+        return
+    }
+
+    s := &id3v2sylt{ textencoding: 3,
+                     language: "eng",
+                     timestampformat: id3v2sylttimestampmilliseconds,
+                     contenttype: id3v2syltcontenttypelyrics,
+                     lines: lines }
+    s1, err := newid3v2syltfromframe(s.frame())
+    if ! (err == nil &&
+          len(s1.lines) == 4 &&
+          s1.lines[3] == lines[3] &&
+          formatlrc(s1.lines[0:1]) == "[00:01.00]One\n") {
+        t.Errorf("Test_parselrc:  failed")
+        return
+    }
+}
+
+// Formatting parsed lyrics gives them back, with any offset applied.
+func Test_formatlrc(t *testing.T) {
+    lrc := "[00:01.50]One\n" +
+           "[00:03.00]Chorus\n" +
+           "[01:05.25]Two\n"
+    lines, err := parselrc(lrc)
+    if ! (err == nil && formatlrc(lines) == lrc) {
+        t.Errorf("Test_formatlrc:  failed")
+        return
+    }
+
+    lines, err = parselrc("[offset:+500]\n" + lrc)
+    lrc1 := formatlrc(lines)
+    lines1, err1 := parselrc(lrc1)
+    if ! (err == nil && err1 == nil &&
+          lrc1 == "[00:01.00]One\n" +
+                  "[00:02.50]Chorus\n" +
+                  "[01:04.75]Two\n" &&
+          reflect.DeepEqual(lines1, lines)) {
+        t.Errorf("Test_formatlrc:  failed, %s", lrc1)
+        return
+    }
+}
+
+
+// Lyrics set from an '.lrc' file are written as USLT and SYLT frames, and
+// from a '.txt' file as a USLT frame.
+func Test_setlyrics(t *testing.T) {
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    if err := i.setlyrics(true, "[00:01.50]One\n[00:03.00]Two\n"); err != nil {
+        t.Errorf("Test_setlyrics:  failed")
+        return
+    }
+
+    i1, err := newid3v2frombytes(i.bytes())
+    if err != nil {
+        t.Errorf("Test_setlyrics:  failed")
+        return
+    }
+    u, err := newid3v2usltfromframe(i1.frame("USLT"))
+    s, err1 := newid3v2syltfromframe(i1.frame("SYLT"))
+    if ! (err == nil && err1 == nil &&
+          u.textencoding == 3 &&
+          u.language == id3v2lyricslanguage &&
+          u.text == "One\nTwo" &&
+          s.timestampformat == id3v2sylttimestampmilliseconds &&
+          s.contenttype == id3v2syltcontenttypelyrics &&
+          reflect.DeepEqual(s.lines,
+                            []id3v2syltline{ { text: "One", timestamp: 1500 },
+                                             { text: "Two", timestamp: 3000 } })) {
+        t.Errorf("Test_setlyrics:  failed")
+        return
+    }
+
+    // Setting lyrics again replaces both frames.
+    if err := i1.setlyrics(false, "Three\nFour\n"); err != nil {
+        t.Errorf("Test_setlyrics:  failed")
+        return
+    }
+    i2, err := newid3v2frombytes(i1.bytes())
+    if err != nil {
+        t.Errorf("Test_setlyrics:  failed")
+        return
+    }
+    u, err = newid3v2usltfromframe(i2.frame("USLT"))
+    if ! (err == nil &&
+          len(i2.frames) == 2 &&
+          i2.frame("SYLT") == nil &&
+          u.text == "Three\nFour") {
+        t.Errorf("Test_setlyrics:  failed")
+        return
+    }
+}
+
+
+// Timestamps in mpeg frames are exported in milliseconds.
+func Test_exportlrc(t *testing.T) {
+    s := &id3v2sylt{ textencoding: 3,
+                     language: id3v2lyricslanguage,
+                     timestampformat: id3v2sylttimestampmpegframes,
+                     contenttype: id3v2syltcontenttypelyrics,
+                     lines: []id3v2syltline{ { text: "One", timestamp: 0 },
+                                             { text: "Two", timestamp: 100 } } }
+    i := &id3v2{ version: 4 }
+    i.setframe(s.frame())
+    stream := append(i.bytes(), testmp3frame()...)
+
+    file := testfile(t, stream)
+    defer os.Remove(file.Name())
+    file.Close()
+    os.Rename(file.Name(), file.Name() + ".mp3")
+    filename := file.Name() + ".mp3"
+    defer os.Remove(filename)
+    defer os.Remove(file.Name() + ".lrc")
+    stdout := testfile(t, nil)
+    defer os.Remove(stdout.Name())
+    defer stdout.Close()
+
+    // Each frame is 1152 / 44100 seconds, so 100 frames are 2612ms.
+    err := exportlrc(nil, stdout, stdout, false, filename, false)
+    lrc, err1 := ioutil.ReadFile(file.Name() + ".lrc")
+    if ! (err == nil && err1 == nil &&
+          string(lrc) == "[00:00.00]One\n[00:02.61]Two\n") {
+        t.Errorf("Test_exportlrc:  failed, %s", lrc)
+        return
+    }
+}
@@ -0,0 +1,150 @@
+// 'imageresize.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "image"
+    "image/color"
+    "image/draw"
+    "image/jpeg"
+
+    // Register decoders with image.Decode().
+    _ "image/gif"
+    _ "image/png"
+
+    _ "golang.org/x/image/bmp"
+)
+
+
+// Downscale an image whose width or height is over maxsize pixels so that
+// it fits in maxsize by maxsize, and re-encode it as jpeg at the given
+// quality.  An image within maxsize, or any image if maxsize is 0, but over
+// maxbytes bytes, unless maxbytes is 0, is re-encoded without scaling if that
+// makes it smaller.  Other images are returned unchanged.
+func resizeimage(data []byte,
+                 maxsize int,
+                 maxbytes int,
+                 quality int) (data1 []byte, resized bool, err error) {
+    src, _, err := image.Decode(bytes.NewReader(data))
+    if err != nil {
+        return nil, false, err
+    }
+
+    bounds := src.Bounds()
+    width := bounds.Dx()
+    height := bounds.Dy()
+    scale := maxsize > 0 && (width > maxsize || height > maxsize)
+    if !scale && (maxbytes == 0 || len(data) <= maxbytes) {
+        return data, false, nil
+    }
+
+    widthnew := width
+    heightnew := height
+    if scale && width > height {
+        widthnew = maxsize
+        heightnew = height * maxsize / width
+    } else if scale {
+        widthnew = width * maxsize / height
+        heightnew = maxsize
+    }
+    if widthnew < 1 {
+        widthnew = 1
+    }
+    if heightnew < 1 {
+        heightnew = 1
+    }
+
+    // Composite onto white as jpeg has no transparency.
+    rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+    draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.White),
+              image.Point{}, draw.Src)
+    draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Over)
+
+    dst := rgba
+    if scale {
+        dst = boxscale(rgba, widthnew, heightnew)
+    }
+
+    var buffer bytes.Buffer
+    if err = jpeg.Encode(&buffer,
+                         dst,
+                         &jpeg.Options{ Quality: quality }); err != nil {
+        return nil, false, err
+    }
+
+    if !scale && buffer.Len() >= len(data) {
+        return data, false, nil
+    }
+
+    return buffer.Bytes(), true, nil
+}
+
+
+// Each destination pixel is the average of the source pixels it covers.
+func boxscale(src *image.RGBA, width int, height int) (dst *image.RGBA) {
+    dst = image.NewRGBA(image.Rect(0, 0, width, height))
+    widthsrc := src.Bounds().Dx()
+    heightsrc := src.Bounds().Dy()
+
+    for y := 0; y < height; y++ {
+        y0 := y * heightsrc / height
+        y1 := (y + 1) * heightsrc / height
+        if y1 == y0 {
+            y1 = y0 + 1
+        }
+
+        for x := 0; x < width; x++ {
+            x0 := x * widthsrc / width
+            x1 := (x + 1) * widthsrc / width
+            if x1 == x0 {
+                x1 = x0 + 1
+            }
+
+            var r, g, b, a, n int
+            for ys := y0; ys < y1; ys++ {
+                j := src.PixOffset(x0, ys)
+                for xs := x0; xs < x1; xs++ {
+                    r += int(src.Pix[j])
+                    g += int(src.Pix[j + 1])
+                    b += int(src.Pix[j + 2])
+                    a += int(src.Pix[j + 3])
+                    j += 4
+                    n++
+                }
+            }
+
+            k := dst.PixOffset(x, y)
+            dst.Pix[k] = uint8(r / n)
+            dst.Pix[k + 1] = uint8(g / n)
+            dst.Pix[k + 2] = uint8(b / n)
+            dst.Pix[k + 3] = uint8(a / n)
+        }
+    }
+
+    return dst
+}
+
+
+// Resize the picture if needed, returning whether it was resized.
+func (a *id3v2apic) resize(maxsize int,
+                           maxbytes int,
+                           quality int) (resized bool, err error) {
+    var data []byte
+    if data, resized, err = resizeimage(a.data,
+                                        maxsize,
+                                        maxbytes,
+                                        quality); err != nil {
+        return false, err
+    }
+
+    if resized {
+        a.mimetype = "image/jpeg"
+        a.data = data
+    }
+
+    return resized, nil
+}
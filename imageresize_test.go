@@ -0,0 +1,122 @@
+// 'imageresize_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "image"
+    "image/color"
+    "image/png"
+    "math/rand"
+    "testing"
+
+    "golang.org/x/image/bmp"
+)
+
+
+func testpng(width int, height int) []byte {
+    img := image.NewRGBA(image.Rect(0, 0, width, height))
+    for y := 0; y < height; y++ {
+        for x := 0; x < width; x++ {
+            img.Set(x, y, color.RGBA{ uint8(x), uint8(y), 0x80, 0xff })
+        }
+    }
+
+    var buffer bytes.Buffer
+    png.Encode(&buffer, img)
+    return buffer.Bytes()
+}
+
+
+func Test_resizeimagenotneeded(t *testing.T) {
+    data := testpng(100, 50)
+    data1, resized, err := resizeimage(data, 100, 0, 85)
+    if ! (err == nil && !resized && bytes.Equal(data, data1)) {
+        t.Errorf("Test_resizeimagenotneeded:  failed")
+        return
+    }
+}
+
+
+func Test_resizeimage(t *testing.T) {
+    data1, resized, err := resizeimage(testpng(400, 200), 100, 0, 85)
+    if ! (err == nil && resized && imagemimetype(data1) == "image/jpeg") {
+        t.Errorf("Test_resizeimage:  failed")
+        return
+    }
+
+    config, _, err := image.DecodeConfig(bytes.NewReader(data1))
+    if ! (err == nil && config.Width == 100 && config.Height == 50) {
+        t.Errorf("Test_resizeimage:  failed")
+        return
+    }
+}
+
+
+func Test_resizeimagebmp(t *testing.T) {
+    img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+    var buffer bytes.Buffer
+    bmp.Encode(&buffer, img)
+
+    data1, resized, err := resizeimage(buffer.Bytes(), 100, 0, 85)
+    if ! (imagemimetype(buffer.Bytes()) == "image/bmp" &&
+          err == nil && resized && imagemimetype(data1) == "image/jpeg") {
+        t.Errorf("Test_resizeimagebmp:  failed")
+        return
+    }
+}
+
+
+// An image within the maximum width and height but over the maximum bytes is
+// re-encoded without scaling.
+func Test_resizeimagemaxbytes(t *testing.T) {
+    img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+    rand.New(rand.NewSource(1)).Read(img.Pix)
+    var buffer bytes.Buffer
+    png.Encode(&buffer, img)
+    data := buffer.Bytes()
+    data1, resized, err := resizeimage(data, 100, len(data) - 1, 85)
+    if ! (err == nil && resized && len(data1) < len(data)) {
+        t.Errorf("Test_resizeimagemaxbytes:  failed")
+        return
+    }
+
+    config, _, err := image.DecodeConfig(bytes.NewReader(data1))
+    if ! (err == nil && config.Width == 100 && config.Height == 100) {
+        t.Errorf("Test_resizeimagemaxbytes:  failed")
+        return
+    }
+
+    data1, resized, err = resizeimage(data, 100, len(data), 85)
+    if ! (err == nil && !resized && bytes.Equal(data, data1)) {
+        t.Errorf("Test_resizeimagemaxbytes:  failed")
+        return
+    }
+}
+
+
+// Pictures which cannot be decoded are skipped with a warning.
+func Test_resizeapics(t *testing.T) {
+    i := newid3v2()
+    i.frames = append(i.frames,
+                      (&id3v2apic{ mimetype: "image/jpeg",
+                                   picturetype: 4,
+                                   data: []byte("\xff\xd8\xff") }).frame(),
+                      (&id3v2apic{ mimetype: "image/png",
+                                   picturetype: id3v2apicfrontcover,
+                                   data: testpng(400, 200) }).frame())
+
+    var buffer bytes.Buffer
+    n := resizeapics(&buffer, i, 100, 0, 85)
+    a, err := newid3v2apicfromframe(i.frames[1])
+    if ! (n == 1 &&
+          buffer.Len() > 0 &&
+          err == nil &&
+          a.mimetype == "image/jpeg") {
+        t.Errorf("Test_resizeapics:  failed")
+        return
+    }
+}
@@ -0,0 +1,177 @@
+// 'inplace.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io"
+    "os"
+)
+
+
+// Returns the id3v2 tag at the start of file and its size in bytes including
+// padding, or nil if there is none.
+func readleadingid3v2(file *os.File) (i *id3v2, size int, err error) {
+    bytes10 := make([]byte, 10)
+    if _, err = file.ReadAt(bytes10, 0); err != nil {
+        if err == io.EOF {
+            return nil, 0, nil
+        }
+        return nil, 0, err
+    }
+
+    if string(bytes10[0:3]) != "ID3" {
+        return nil, 0, nil
+    }
+
+    size = id3v2tagsize(bytes10)
+    bytes := make([]byte, size)
+    if _, err = file.ReadAt(bytes, 0); err != nil {
+        return nil, 0, err
+    }
+
+    if i, err = newid3v2frombytes(bytes); err != nil {
+        return nil, 0, err
+    }
+
+    return i, size, nil
+}
+
+
+// Whether i fits in size bytes, the size of the existing tag including its
+// padding, when written without padding or footer.
+func id3v2fitsinplace(i *id3v2, size int) bool {
+    i1 := *i
+    i1.footer = false
+    i1.padding = 0
+    return len(i1.bytes()) <= size
+}
+
+
+// Overwrite the id3v2 tag at the start of file if i fits in size bytes, see
+// id3v2fitsinplace().  Any remaining space becomes padding.
+func writeid3v2inplace(file *os.File,
+                       i *id3v2,
+                       size int) (ok bool, err error) {
+    if !id3v2fitsinplace(i, size) {
+        return false, nil
+    }
+
+    // Note padding and footer are mutually exclusive.
+    i.footer = false
+    i.padding = 0
+    i.padding = size - len(i.bytes())
+
+    if _, err = file.WriteAt(i.bytes(), 0); err != nil {
+        return false, err
+    }
+
+    return true, nil
+}
+
+
+// Overwrite the id3v1 tag at the end of file, or append i if there is none.
+func writeid3v1inplace(file *os.File, i *id3v1) (err error) {
+    var end int64
+    if end, err = file.Seek(0, io.SeekEnd); err != nil {
+        return err
+    }
+
+    offset := end
+    if end >= 128 {
+        bytes3 := make([]byte, 3)
+        if _, err = file.ReadAt(bytes3, end - 128); err != nil {
+            return err
+        }
+        if string(bytes3) == "TAG" {
+            offset = end - 128
+        }
+    }
+
+    if _, err = file.WriteAt(i.bytes(), offset); err != nil {
+        return err
+    }
+
+    return nil
+}
+
+
+// Whether file ends with id3v1 extended and id3v1 tags.  Note the file
+// offset is left unchanged.
+func hasid3v1extended(file *os.File) bool {
+    fileinfo, err := file.Stat()
+    if err != nil {
+        return false
+    }
+    end := fileinfo.Size()
+    if end < 128 + id3v1extendedsize {
+        return false
+    }
+
+    bytes := make([]byte, id3v1extendedsize + 3)
+    if _, err = file.ReadAt(bytes, end - 128 - id3v1extendedsize); err != nil {
+        return false
+    }
+
+    return string(bytes[0:4]) == "TAG+" &&
+           string(bytes[id3v1extendedsize:]) == "TAG"
+}
+
+
+// Overwrite the id3v1 extended tag before the id3v1 tag at the end of file.
+// The file must already have one, see hasid3v1extended().
+func writeid3v1extendedinplace(file *os.File, e *id3v1extended) (err error) {
+    var end int64
+    if end, err = file.Seek(0, io.SeekEnd); err != nil {
+        return err
+    }
+
+    if _, err = file.WriteAt(e.bytes(),
+                             end - 128 - id3v1extendedsize); err != nil {
+        return err
+    }
+
+    return nil
+}
+
+
+// Returns the id3v1 tag at the end of file merged with any id3v1 extended tag,
+// or nil if there is none.  Note the strings are as read from the tag.
+func readtrailingid3v1(file *os.File) (i *id3v1, err error) {
+    fileinfo, err := file.Stat()
+    if err != nil {
+        return nil, err
+    }
+    end := fileinfo.Size()
+    if end < 128 {
+        return nil, nil
+    }
+
+    bytes := make([]byte, 128)
+    if _, err = file.ReadAt(bytes, end - 128); err != nil {
+        return nil, err
+    }
+    if string(bytes[0:3]) != "TAG" {
+        return nil, nil
+    }
+
+    if i, err = newid3v1frombytes(bytes); err != nil {
+        return nil, err
+    }
+
+    if hasid3v1extended(file) {
+        bytes1 := make([]byte, id3v1extendedsize)
+        if _, err = file.ReadAt(bytes1, end - 128 - id3v1extendedsize); err != nil {
+            return nil, err
+        }
+        var e *id3v1extended
+        if e, err = newid3v1extendedfrombytes(bytes1); err != nil {
+            return nil, err
+        }
+        e.merge(i, bytes)
+    }
+
+    return i, nil
+}
@@ -0,0 +1,132 @@
+// 'inplace_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "testing"
+)
+
+
+// Returns a temporary file holding bytes, to be removed by the caller.
+func testfile(t *testing.T, bytes []byte) *os.File {
+    file, err := ioutil.TempFile("", "mp3adora")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err = file.Write(bytes); err != nil {
+        t.Fatal(err)
+    }
+    return file
+}
+
+
+func Test_id3v2fitsinplace(t *testing.T) {
+    i := &id3v2{ version: 4, padding: 100 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    size := len(i.bytes())
+
+    // Note the padding and footer of the new tag are not counted.
+    i.setframe(newid3v2textframe("TPE1", "Artist"))
+    i.padding = 1000
+    i.footer = true
+    if !id3v2fitsinplace(i, size) {
+        t.Errorf("Test_id3v2fitsinplace:  failed")
+        return
+    }
+
+    i.setframe(newid3v2textframe("TALB", string(make([]byte, 100))))
+    if id3v2fitsinplace(i, size) {
+        t.Errorf("Test_id3v2fitsinplace:  failed")
+        return
+    }
+}
+
+
+func Test_writeid3v2inplace(t *testing.T) {
+    i := &id3v2{ version: 4, padding: 64 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    tag := i.bytes()
+    stream := append(append([]byte{}, tag...), testmp3frame()...)
+
+    file := testfile(t, stream)
+    defer os.Remove(file.Name())
+    defer file.Close()
+
+    i1, size, err := readleadingid3v2(file)
+    if ! (err == nil && i1 != nil && size == len(tag)) {
+        t.Errorf("Test_writeid3v2inplace:  failed")
+        return
+    }
+
+    i1.setframe(newid3v2textframe("TPE1", "Artist"))
+    ok, err := writeid3v2inplace(file, i1, size)
+    i2, size2, err2 := readleadingid3v2(file)
+    bytes1, _ := ioutil.ReadFile(file.Name())
+    if ! (ok && err == nil && err2 == nil &&
+          size2 == size &&
+          i2.frame("TPE1").text() == "Artist" &&
+          i2.padding > 0 && len(i2.bytes()) == size &&
+          bytes.Equal(bytes1[size:], testmp3frame())) {
+        t.Errorf("Test_writeid3v2inplace:  failed")
+        return
+    }
+
+    // Too big, so the file is unchanged.
+    i2.setframe(newid3v2textframe("TALB", string(make([]byte, 100))))
+    ok, err = writeid3v2inplace(file, i2, size)
+    bytes2, _ := ioutil.ReadFile(file.Name())
+    if ! (!ok && err == nil && bytes.Equal(bytes1, bytes2)) {
+        t.Errorf("Test_writeid3v2inplace:  failed")
+        return
+    }
+}
+
+
+func Test_writeid3v1inplace(t *testing.T) {
+    file := testfile(t, testmp3frame())
+    defer os.Remove(file.Name())
+    defer file.Close()
+
+    for _, title := range []string{ "One", "Two" } {
+        err := writeid3v1inplace(file,
+                                 newid3v1fromitems(title, "", "", "", "", 1, 0))
+        i, err1 := readtrailingid3v1(file)
+        fileinfo, _ := file.Stat()
+        if ! (err == nil && err1 == nil &&
+              i.title == title &&
+              fileinfo.Size() == 417 + 128) {
+            t.Errorf("Test_writeid3v1inplace:  failed")
+            return
+        }
+    }
+}
+
+
+// Rewriting keeps what updating in place keeps.
+func Test_mp3framecopyhandler(t *testing.T) {
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    tag := i.bytes()
+    id3v1 := newid3v1fromitems("Title", "", "", "", "", 0, 0).bytes()
+
+    kept := []byte{}
+    kept = append(kept, testmp3frame()...)
+    kept = append(kept, "junk"...)
+    kept = append(kept, testape()...)
+    kept = append(kept, tag...)
+    stream := append(append(append([]byte{}, tag...), kept...), id3v1...)
+
+    var buffer bytes.Buffer
+    h := newmp3adoramp3framecopyhandler(&buffer, false)
+    if _, err := newmp3adora(h).parse(bytes.NewReader(stream));
+       ! (err == nil && bytes.Equal(buffer.Bytes(), kept)) {
+        t.Errorf("Test_mp3framecopyhandler:  failed")
+        return
+    }
+}
@@ -0,0 +1,228 @@
+// 'lyrics3.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+)
+
+
+// See:  http://id3.org/Lyrics3
+//       http://id3.org/Lyrics3v2
+// Lyrics3 v1 is 'LYRICSBEGIN', up to 5100 bytes of lyrics and 'LYRICSEND'.
+// Lyrics3 v2 is 'LYRICSBEGIN', fields, a six digit size and 'LYRICS200',
+// where each field is a three character id, a five digit size and the value.
+type lyrics3field struct {
+    id string
+    value string
+}
+
+
+type lyrics3 struct {
+    version int
+    lyrics string
+    fields []lyrics3field
+}
+
+
+const lyrics3v1maxsize = 5100
+const lyrics3v2maxsize = 999999 + 15
+
+
+func newlyrics3frombytes(bytes []byte) (l *lyrics3, err error) {
+    if len(bytes) < 20 || string(bytes[0:11]) != "LYRICSBEGIN" {
+        return nil, fmt.Errorf("Unable to find lyrics3 header.")
+    }
+
+    l = new(lyrics3)
+
+    if string(bytes[len(bytes) - 9:]) == "LYRICSEND" {
+        l.version = 1
+        l.lyrics = string(bytes[11:len(bytes) - 9])
+        return l, nil
+    }
+
+    if len(bytes) < 26 || string(bytes[len(bytes) - 9:]) != "LYRICS200" {
+        return nil, fmt.Errorf("Unable to find lyrics3 footer.")
+    }
+
+    l.version = 2
+    fields := bytes[11:len(bytes) - 15]
+    for len(fields) > 0 {
+        n, ok := lyrics3digits(fields, 3, 5)
+        if !ok || 8 + n > len(fields) {
+            return nil, fmt.Errorf("Unable to parse lyrics3 field.")
+        }
+        l.fields = append(l.fields,
+                          lyrics3field{ id: string(fields[0:3]),
+                                        value: string(fields[8:8 + n]) })
+        fields = fields[8 + n:]
+    }
+
+    return l, nil
+}
+
+
+// Returns the decimal number of width digits at offset.
+func lyrics3digits(bytes []byte, offset int, width int) (n int, ok bool) {
+    if offset + width > len(bytes) {
+        return 0, false
+    }
+    for _, b := range bytes[offset:offset + width] {
+        if b < '0' || b > '9' {
+            return 0, false
+        }
+    }
+    n, _ = strconv.Atoi(string(bytes[offset:offset + width]))
+    return n, true
+}
+
+
+// Reads the lyrics3 block at the start of reader.  Lyrics3 v2 fields are
+// followed until the size and 'LYRICS200', otherwise lyrics3 v1 is searched
+// for 'LYRICSEND'.  Note lyrics3 v2 blocks may be larger than the buffer, so
+// are read as they are followed, and if there turns out to be no block then
+// ok is false and bytes are those read.  Reader must buffer at least
+// lyrics3v1maxsize + 20 bytes.
+func readlyrics3(reader *bufio.Reader) (bytes []byte, ok bool, err error) {
+    size1 := 0
+    if bytes, _ := reader.Peek(11 + lyrics3v1maxsize + 9); len(bytes) > 11 {
+        if j := strings.Index(string(bytes[11:]), "LYRICSEND"); j != -1 {
+            size1 = 11 + j + 9
+        }
+    }
+
+    read := func(n int) (err error) {
+        bytes1 := make([]byte, n)
+        n, err = io.ReadFull(reader, bytes1)
+        bytes = append(bytes, bytes1[0:n]...)
+        return err
+    }
+
+    // Note position is from the start of the block, offset from the start
+    // of what remains to be read.
+    position := 11
+    for position + 15 <= lyrics3v2maxsize {
+        offset := position - len(bytes)
+        if offset + 15 > reader.Size() {
+            if err = read(offset); err == io.ErrUnexpectedEOF {
+                break
+            }
+            if err != nil {
+                return bytes, false, err
+            }
+            offset = 0
+        }
+
+        peek, _ := reader.Peek(offset + 15)
+        if len(peek) < offset + 15 {
+            break
+        }
+
+        if string(peek[offset + 6:offset + 15]) == "LYRICS200" {
+            if n, ok := lyrics3digits(peek, offset, 6); ok && n == position {
+                if err = read(offset + 15); err != nil {
+                    return bytes, false, err
+                }
+                return bytes, true, nil
+            }
+            break
+        }
+
+        n, ok := lyrics3digits(peek, offset + 3, 5)
+        if !ok {
+            break
+        }
+        position += 8 + n
+    }
+
+    if len(bytes) > 0 {
+        return bytes, false, nil
+    }
+
+    if size1 > 0 {
+        if err = read(size1); err != nil {
+            return bytes, false, err
+        }
+        return bytes, true, nil
+    }
+
+    return nil, false, nil
+}
+
+
+// Returns the size of a lyrics3 block from its last bytes, i.e. those ending
+// at end, or 0 if there is none.  Lyrics3 v1 needs searching backwards for
+// 'LYRICSBEGIN', though no further back than start.
+func lyrics3tailsize(readat func(offset int64, n int) []byte,
+                     start int64,
+                     end int64) (size int64) {
+    if bytes := readat(end - 15, 15); bytes != nil &&
+       string(bytes[6:15]) == "LYRICS200" {
+        n, ok := lyrics3digits(bytes, 0, 6)
+        if !ok {
+            return 0
+        }
+        if begin := readat(end - 15 - int64(n), 11);
+           begin == nil || string(begin) != "LYRICSBEGIN" {
+            return 0
+        }
+        return int64(n) + 15
+    }
+
+    if bytes := readat(end - 9, 9); bytes != nil &&
+       string(bytes) == "LYRICSEND" {
+        offset := end - (11 + lyrics3v1maxsize + 9)
+        if offset < start {
+            offset = start
+        }
+        bytes := readat(offset, int(end - offset))
+        if bytes == nil {
+            return 0
+        }
+        if j := strings.LastIndex(string(bytes), "LYRICSBEGIN"); j != -1 {
+            return int64(len(bytes) - j)
+        }
+    }
+
+    return 0
+}
+
+
+// Whether file has a lyrics3 block before its id3v1 and id3v1 extended tags.
+// Note the file offset is left unchanged.
+func haslyrics3(file *os.File) bool {
+    fileinfo, err := file.Stat()
+    if err != nil {
+        return false
+    }
+    end := fileinfo.Size()
+
+    readat := func(offset int64, n int) []byte {
+        if offset < 0 {
+            return nil
+        }
+        bytes := make([]byte, n)
+        if _, err := file.ReadAt(bytes, offset); err != nil {
+            return nil
+        }
+        return bytes
+    }
+
+    if bytes := readat(end - 128, 3); bytes != nil && string(bytes) == "TAG" {
+        end -= 128
+        if hasid3v1extended(file) {
+            end -= id3v1extendedsize
+        }
+    }
+
+    return lyrics3tailsize(readat, 0, end) > 0
+}
@@ -0,0 +1,114 @@
+// 'lyrics3_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "fmt"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+
+func testlyrics3v2(fields []lyrics3field) []byte {
+    s := "LYRICSBEGIN"
+    for _, field := range fields {
+        s += fmt.Sprintf("%s%05d%s", field.id, len(field.value), field.value)
+    }
+    return []byte(fmt.Sprintf("%s%06dLYRICS200", s, len(s)))
+}
+
+
+func Test_newlyrics3frombytes(t *testing.T) {
+    l, err := newlyrics3frombytes([]byte("LYRICSBEGINOne\r\nTwoLYRICSEND"))
+    if ! (err == nil && l.version == 1 && l.lyrics == "One\r\nTwo") {
+        t.Errorf("Test_newlyrics3frombytes:  failed")
+        return
+    }
+
+    fields := []lyrics3field{ lyrics3field{ id: "IND", value: "10" },
+                              lyrics3field{ id: "LYR", value: "One\r\nTwo" } }
+    l, err = newlyrics3frombytes(testlyrics3v2(fields))
+    if ! (err == nil && l.version == 2 && reflect.DeepEqual(l.fields, fields)) {
+        t.Errorf("Test_newlyrics3frombytes:  failed")
+        return
+    }
+
+    if _, err = newlyrics3frombytes([]byte("LYRICSBEGINOne\r\nTwo")); err == nil {
+        t.Errorf("Test_newlyrics3frombytes:  failed")
+        return
+    }
+}
+
+
+// Lyrics3 v2 blocks may be larger than the parser's buffer.  Text which only
+// starts like a lyrics3 block is unrecognised.
+func Test_parselyrics3(t *testing.T) {
+    v1 := []byte("LYRICSBEGINOneLYRICSEND")
+    v2 := testlyrics3v2([]lyrics3field{
+              lyrics3field{ id: "LYR", value: strings.Repeat("One\r\n", 4000) },
+              lyrics3field{ id: "IND", value: "10" } })
+    bad := []byte("LYRICSBEGINLYR")
+
+    for _, lyrics3 := range [][]byte{ v1, v2, bad } {
+        stream := []byte{}
+        stream = append(stream, testmp3frame()...)
+        stream = append(stream, lyrics3...)
+        stream = append(stream, testmp3frame()...)
+
+        expected := []string{ "mp3frame 417",
+                              fmt.Sprintf("lyrics3 %d", len(lyrics3)),
+                              "mp3frame 417" }
+        if bytes.Equal(lyrics3, bad) {
+            expected[1] = fmt.Sprintf("unrecognised %d", len(lyrics3))
+        }
+
+        parsed, err := testparse(&testreader{ bytes.NewReader(stream) })
+        if ! (err == nil && reflect.DeepEqual(parsed, expected)) {
+            t.Errorf("Test_parselyrics3:  failed, %v", parsed)
+            return
+        }
+    }
+
+    // Truncated.
+    stream := append(testmp3frame(), v2[0:len(v2) - 20]...)
+    parsed, err := testparse(&testreader{ bytes.NewReader(stream) })
+    if ! (err == nil &&
+          len(parsed) == 2 &&
+          strings.HasPrefix(parsed[1], "unrecognised")) {
+        t.Errorf("Test_parselyrics3:  failed, %v", parsed)
+        return
+    }
+}
+
+
+func Test_lyrics3tailsize(t *testing.T) {
+    v1 := []byte("LYRICSBEGINOneLYRICSEND")
+    v2 := testlyrics3v2([]lyrics3field{ lyrics3field{ id: "LYR",
+                                                      value: "One" } })
+
+    for _, lyrics3 := range [][]byte{ v1, v2, []byte("LYRICSEND") } {
+        stream := append(testmp3frame(), lyrics3...)
+        readat := func(offset int64, n int) []byte {
+            if offset < 0 || offset + int64(n) > int64(len(stream)) {
+                return nil
+            }
+            return stream[offset:offset + int64(n)]
+        }
+
+        expected := int64(len(lyrics3))
+        if len(lyrics3) == 9 {
+            expected = 0
+        }
+        if size := lyrics3tailsize(readat,
+                                   0,
+                                   int64(len(stream))); size != expected {
+            t.Errorf("Test_lyrics3tailsize:  failed, %d", size)
+            return
+        }
+    }
+}
@@ -26,8 +26,18 @@ func _main(stdin *os.File,
         fmt.Fprintln(stdout, "Usage:  mp3adora [ -v ] command options ...")
         fmt.Fprintln(stdout)
         fmt.Fprintln(stdout, "Commands:")
+        fmt.Fprintln(stdout, "chapters    Write id3v2 chapters from a chapter list")
+        fmt.Fprintln(stdout, "cut         Copy a time range of mp3 frames to a new file")
+        fmt.Fprintln(stdout, "exportlrc   Write synchronised lyrics to .lrc files")
+        fmt.Fprintln(stdout, "extractart  Write embedded pictures to image files")
+        fmt.Fprintln(stdout, "fixencoding Rewrite legacy encoded tags as UTF-8 id3v2.4")
+        fmt.Fprintln(stdout, "fixvbr      Add or rebuild the Xing header of mp3 files")
+        fmt.Fprintln(stdout, "join        Join mp3 files into one stream")
+        fmt.Fprintln(stdout, "normalise   Normalise Unicode text in id3v2 tags")
+        fmt.Fprintln(stdout, "resizeart   Downscale embedded pictures")
         fmt.Fprintln(stdout, "show        Parse contents of mp3 files")
-        fmt.Fprintln(stdout, "tagalbum    Tag mp3 files with id3v1 tags")
+        fmt.Fprintln(stdout, "tagalbum    Tag mp3 files with id3v1 and id3v2 tags")
+        fmt.Fprintln(stdout, "upgradetags Rewrite id3v2.2 and id3v2.3 tags as id3v2.4")
         fmt.Fprintln(stdout)
         fmt.Fprintln(stdout, "Options:")
         flagset.PrintDefaults()
@@ -36,17 +46,79 @@ func _main(stdin *os.File,
     flagv := flagset.Bool("v",
                           false,
                           "Verbose")
+    flaglistencodings := flagset.Bool("list-encodings",
+                                      false,
+                                      "List encodings for id3v1 tags")
 
     // Note flagset.Parse() will also handle '-h' and '--help' and will exit
     // with exit status 2.
     flagset.Parse(args[1:])
 
+    if *flaglistencodings {
+        listencodings(stdout)
+        return exitsuccess
+    }
+
     if len(flagset.Args()) == 0 {
         flagset.Usage()
         return exitfailure
     }
 
     switch {
+        case flagset.Args()[0] == "chapters":
+            return mainchapters(stdin,
+                                stdout,
+                                stderr,
+                                *flagv,
+                                flagset.Args()[1:])
+        case flagset.Args()[0] == "cut":
+            return maincut(stdin,
+                           stdout,
+                           stderr,
+                           *flagv,
+                           flagset.Args()[1:])
+        case flagset.Args()[0] == "exportlrc":
+            return mainexportlrc(stdin,
+                                 stdout,
+                                 stderr,
+                                 *flagv,
+                                 flagset.Args()[1:])
+        case flagset.Args()[0] == "extractart":
+            return mainextractart(stdin,
+                                  stdout,
+                                  stderr,
+                                  *flagv,
+                                  flagset.Args()[1:])
+        case flagset.Args()[0] == "fixencoding":
+            return mainfixencoding(stdin,
+                                   stdout,
+                                   stderr,
+                                   *flagv,
+                                   flagset.Args()[1:])
+        case flagset.Args()[0] == "fixvbr":
+            return mainfixvbr(stdin,
+                              stdout,
+                              stderr,
+                              *flagv,
+                              flagset.Args()[1:])
+        case flagset.Args()[0] == "join":
+            return mainjoin(stdin,
+                            stdout,
+                            stderr,
+                            *flagv,
+                            flagset.Args()[1:])
+        case flagset.Args()[0] == "normalise":
+            return mainnormalise(stdin,
+                                 stdout,
+                                 stderr,
+                                 *flagv,
+                                 flagset.Args()[1:])
+        case flagset.Args()[0] == "resizeart":
+            return mainresizeart(stdin,
+                                 stdout,
+                                 stderr,
+                                 *flagv,
+                                 flagset.Args()[1:])
         case flagset.Args()[0] == "show":
             return mainshow(stdin,
                             stdout,
@@ -59,6 +131,12 @@ func _main(stdin *os.File,
                                 stderr,
                                 *flagv,
                                 flagset.Args()[1:])
+        case flagset.Args()[0] == "upgradetags":
+            return mainupgradetags(stdin,
+                                   stdout,
+                                   stderr,
+                                   *flagv,
+                                   flagset.Args()[1:])
     }
 
     flagset.Usage()
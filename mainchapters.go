@@ -0,0 +1,187 @@
+// 'mainchapters.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+)
+
+
+func chapters(stdin *os.File,
+              stdout *os.File,
+              stderr *os.File,
+              verbose bool,
+              filenamechapters string,
+              filename string,
+              padding int,
+              dryrun bool) (err error) {
+    text, err := ioutil.ReadFile(filenamechapters)
+    if err != nil {
+        return err
+    }
+
+    chapterlist, err := parsechapters(string(text))
+    if err != nil {
+        return err
+    }
+    if len(chapterlist) == 0 {
+        return fmt.Errorf("Unable to find chapters in %s", filenamechapters)
+    }
+
+    file, err := os.OpenFile(filename, os.O_RDWR, 0)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    h := newmp3adoraframeindexhandler()
+    mp3adora := newmp3adora(h)
+    if _, err = mp3adora.parse(file); err != nil {
+        return err
+    }
+    if len(h.frames) == 0 {
+        return fmt.Errorf("Unable to find mp3 frames in %s", filename)
+    }
+
+    id3v2, size, err := readleadingid3v2(file)
+    if err != nil {
+        return err
+    }
+    if id3v2 == nil {
+        id3v2 = newid3v2()
+    }
+    if err = upgradeid3v2(stdout, id3v2); err != nil {
+        return err
+    }
+
+    duration := int(h.duration())
+    for _, c := range chapterlist {
+        if c.time >= duration {
+            return fmt.Errorf("Chapter %s starts after the end", c.title)
+        }
+    }
+
+    // Offsets are from the beginning of the new file, so first find the size
+    // of the new tag.  Note this does not depend on the offsets themselves.
+    id3v2.setchapters(chapterlist,
+                      duration,
+                      func(starttime int,
+                           endtime int) (uint32, uint32) {
+                          return 0, 0
+                      })
+
+    id3v2.footer = false
+    id3v2.padding = 0
+    inplace := size > 0 && len(id3v2.bytes()) <= size
+    sizenew := size
+    if !inplace {
+        id3v2.padding = padding
+        sizenew = len(id3v2.bytes())
+    }
+
+    offset := func(j int) uint32 {
+        if j >= len(h.frames) {
+            return uint32(sizenew + h.end() - size)
+        }
+        return uint32(sizenew + h.frames[j].offset - size)
+    }
+
+    id3v2.setchapters(chapterlist,
+                      duration,
+                      func(starttime int,
+                           endtime int) (uint32, uint32) {
+                          return offset(h.frameat(float64(starttime))),
+                                 offset(h.frameat(float64(endtime)))
+                      })
+
+    for j, c := range chapterlist {
+        startoffset := offset(h.frameat(float64(c.time)))
+        fmt.Fprintf(stdout,
+                    "Chapter %d %s offset %d %s\n",
+                    j + 1,
+                    formatchaptertime(c.time),
+                    startoffset,
+                    c.title)
+    }
+
+    if dryrun {
+        return nil
+    }
+
+    if inplace {
+        if verbose {
+            fmt.Fprintf(stdout, "Updating tags in place\n")
+        }
+        _, err = writeid3v2inplace(file, id3v2, size)
+        return err
+    }
+
+    if verbose {
+        fmt.Fprintf(stdout, "Rewriting file\n")
+    }
+    return replaceleadingid3v2(filename, id3v2, size)
+}
+
+
+func formatchaptertime(time int) string {
+    return fmt.Sprintf("%02d:%02d:%02d.%03d",
+                       time / 3600000,
+                       time / 60000 % 60,
+                       time / 1000 % 60,
+                       time % 1000)
+}
+
+
+func mainchapters(stdin *os.File,
+                  stdout *os.File,
+                  stderr *os.File,
+                  verbose bool,
+                  args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("chapters", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] chapters [ options ] chapterfile filename")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Chapter files are cue sheets or lines of 'HH:MM:SS title'.")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagpadding := flagset.Int("padding",
+                               1024,
+                               "Id3v2 padding in bytes when rewriting")
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) != 2 {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    if err := chapters(stdin,
+                       stdout,
+                       stderr,
+                       verbose,
+                       flagset.Args()[0],
+                       flagset.Args()[1],
+                       *flagpadding,
+                       *flagn); err != nil {
+        fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+        return exitfailure
+    }
+
+    return exitsuccess
+}
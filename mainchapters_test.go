@@ -0,0 +1,94 @@
+// 'mainchapters_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io/ioutil"
+    "os"
+    "testing"
+)
+
+
+// The chapter offsets are those of the mp3 frames at the chapter times, in
+// the file with the new tag, whether updated in place or rewritten.
+func Test_chapters(t *testing.T) {
+    for _, padding := range []int{ 1000, 0 } {
+        i := &id3v2{ version: 4, padding: padding }
+        i.setframe(newid3v2textframe("TIT2", "Title"))
+        stream := i.bytes()
+        for j := 0; j < 10; j++ {
+            frame := testmp3frame()
+            frame[100] = byte(j)
+            stream = append(stream, frame...)
+        }
+        stream = append(stream,
+                        newid3v1fromitems("Title", "", "", "", "", 0, 0).bytes()...)
+
+        file := testfile(t, stream)
+        defer os.Remove(file.Name())
+        file.Close()
+        filechapters := testfile(t, []byte("0:00 One\n0:00.1 Two\n"))
+        defer os.Remove(filechapters.Name())
+        filechapters.Close()
+        stdout := testfile(t, nil)
+        defer os.Remove(stdout.Name())
+        defer stdout.Close()
+
+        err := chapters(nil,
+                        stdout,
+                        stdout,
+                        false,
+                        filechapters.Name(),
+                        file.Name(),
+                        64,
+                        false)
+        if err != nil {
+            t.Errorf("Test_chapters:  failed, %s", err)
+            return
+        }
+
+        file1, err := os.Open(file.Name())
+        if err != nil {
+            t.Errorf("Test_chapters:  failed")
+            return
+        }
+        defer file1.Close()
+        i1, size, err := readleadingid3v2(file1)
+        bytes, err1 := ioutil.ReadFile(file.Name())
+        if ! (err == nil && err1 == nil &&
+              i1.frame("TIT2").text() == "Title" &&
+              (size == len(i.bytes())) == (padding > 0) &&
+              len(bytes) == size + 10 * 417 + 128) {
+            t.Errorf("Test_chapters:  failed")
+            return
+        }
+
+        chaps := []*id3v2chap{}
+        for _, f := range i1.frames {
+            if c, err := newid3v2chapfromframe(4, f); err == nil {
+                chaps = append(chaps, c)
+            }
+        }
+
+        // Each frame is 1152 / 44100 seconds, so 100ms is in frame 3 and
+        // Two starts at frame 4.  Two ends at the end of the frames, before
+        // the id3v1 tag.
+        if ! (len(chaps) == 2 &&
+              chaps[0].starttime == 0 &&
+              chaps[0].endtime == 100 &&
+              chaps[0].startoffset == uint32(size) &&
+              chaps[0].endoffset == uint32(size + 4 * 417) &&
+              chaps[1].starttime == 100 &&
+              chaps[1].endtime == 261 &&
+              chaps[1].startoffset == uint32(size + 4 * 417) &&
+              chaps[1].endoffset == uint32(size + 10 * 417) &&
+              bytes[chaps[1].startoffset] == 0xff &&
+              bytes[chaps[1].startoffset + 100] == 4) {
+            t.Errorf("Test_chapters:  failed, %d", padding)
+            return
+        }
+    }
+}
@@ -0,0 +1,260 @@
+// 'maincut.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "strconv"
+)
+
+
+// Parse '[[HH:]MM:]SS', where seconds may have a fraction.  Times are in
+// milliseconds.
+func parsetime(s string) (time int, err error) {
+    regexptime :=
+        regexp.MustCompile(`^(?:(?:([0-9]+):)?([0-9]+):)?([0-9]+(?:\.[0-9]+)?)$`)
+
+    result := regexptime.FindStringSubmatch(s)
+    if result == nil {
+        return 0, fmt.Errorf("Unable to parse time %s", s)
+    }
+
+    hours, _ := strconv.Atoi(result[1])
+    minutes, _ := strconv.Atoi(result[2])
+    seconds, _ := strconv.ParseFloat(result[3], 64)
+    return (hours * 3600 + minutes * 60) * 1000 + int(seconds * 1000 + 0.5), nil
+}
+
+
+// Returns the first frame to copy so that the frame at start has the main
+// data it uses from the bit reservoir, and whether that was all found.
+// Frames before a Xing frame or a frame without side information are not
+// used.
+func reservoirstart(frames []*mp3frameindex, start int) (first int, ok bool) {
+    sideinfo := frames[start].mp3header.sideinfo
+    if sideinfo == nil {
+        return start, true
+    }
+
+    first = start
+    available := 0
+    for first > 0 && available < sideinfo.maindatabegin {
+        f := frames[first - 1]
+        if f.xing != nil || f.mp3header.sideinfo == nil {
+            break
+        }
+        first--
+        available += f.size - f.mp3header.maindataoffset()
+    }
+    return first, available >= sideinfo.maindatabegin
+}
+
+
+// Remove the frames of i giving times or the duration, as they no longer
+// match once cut.
+func cutid3v2(stdout io.Writer, i *id3v2) (err error) {
+    for _, id := range []string{ "CHAP", "CTOC", "SYLT", "TLEN" } {
+        if i.frame(id) != nil {
+            fmt.Fprintf(stdout, "Removing %s frames\n", id)
+            i.removeframes(id)
+        }
+    }
+    return nil
+}
+
+
+// Copy the mp3 frames of filename from starttime up to endtime, or the end if
+// endtime is -1, to filenameout, with the tags and a new Xing header.  Frames
+// are not re-encoded so times are rounded to frames.  The frame at starttime
+// may use main data from earlier frames, the bit reservoir, so these frames
+// are also copied but silenced.  Chapters, synchronised lyrics and the
+// length are removed from the id3v2 tags.
+func cut(stdin *os.File,
+         stdout *os.File,
+         stderr *os.File,
+         verbose bool,
+         filename string,
+         starttime int,
+         endtime int,
+         filenameout string,
+         dryrun bool) (err error) {
+    if filenameout == filename {
+        return fmt.Errorf("Unable to cut %s to itself", filename)
+    }
+
+    file, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    h := newmp3adoraframeindexhandler()
+    mp3adora := newmp3adora(h)
+    if _, err = mp3adora.parse(file); err != nil {
+        return err
+    }
+
+    start := h.frameat(float64(starttime))
+    if start < len(h.frames) && h.frames[start].xing != nil {
+        start++
+    }
+    end := len(h.frames)
+    if endtime != -1 {
+        end = h.frameat(float64(endtime))
+    }
+    if start >= end {
+        return fmt.Errorf("Unable to find mp3 frames from %s in %s",
+                          formatchaptertime(starttime),
+                          filename)
+    }
+
+    first, ok := reservoirstart(h.frames, start)
+    if !ok {
+        fmt.Fprintf(stderr,
+                    "mp3adora: Unable to find %d bytes of main data before frame %d\n",
+                    h.frames[start].mp3header.sideinfo.maindatabegin,
+                    start)
+    }
+
+    headers := []*mp3header{}
+    for _, f := range h.frames[first:end] {
+        headers = append(headers, f.mp3header)
+    }
+
+    template := make([]byte, 4)
+    if _, err = file.ReadAt(template, int64(h.frames[first].offset)); err != nil {
+        return err
+    }
+    xing, err := newxingframe(template, headers, nil)
+    if err != nil {
+        return err
+    }
+
+    endtime1 := h.duration()
+    if end < len(h.frames) {
+        endtime1 = h.frames[end].time
+    }
+    fmt.Fprintf(stdout,
+                "Cutting frames %d to %d, %s to %s\n",
+                start,
+                end - 1,
+                formatchaptertime(int(h.frames[start].time)),
+                formatchaptertime(int(endtime1)))
+    if verbose {
+        fmt.Fprintf(stdout,
+                    "Silencing %d frames for the bit reservoir\n",
+                    start - first)
+    }
+
+    if dryrun {
+        return nil
+    }
+
+    if _, err = file.Seek(0, io.SeekStart); err != nil {
+        return err
+    }
+
+    fileout, err := os.Create(filenameout)
+    if err != nil {
+        return err
+    }
+    defer fileout.Close()
+
+    mp3adora = newmp3adora(newmp3adoracuthandler(fileout,
+                                                  first,
+                                                  start,
+                                                  end,
+                                                  xing,
+                                                  true,
+                                                  func(i *id3v2) (err error) {
+                                                      return cutid3v2(stdout, i)
+                                                  }))
+    if _, err = mp3adora.parse(file); err != nil {
+        os.Remove(filenameout)
+        return err
+    }
+
+    if err = fileout.Close(); err != nil {
+        os.Remove(filenameout)
+        return err
+    }
+
+    return nil
+}
+
+
+func maincut(stdin *os.File,
+             stdout *os.File,
+             stderr *os.File,
+             verbose bool,
+             args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("cut", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] cut [ options ] -o outfilename filename")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Times are '[[HH:]MM:]SS', where seconds may have a fraction.")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagstart := flagset.String("start",
+                                "0",
+                                "Start time")
+    flagend := flagset.String("end",
+                              "",
+                              "End time, otherwise the end")
+    flago := flagset.String("o",
+                            "",
+                            "Output filename")
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) != 1 || *flago == "" {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    starttime, err := parsetime(*flagstart)
+    if err != nil {
+        fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+        return exitfailure
+    }
+
+    endtime := -1
+    if *flagend != "" {
+        if endtime, err = parsetime(*flagend); err != nil {
+            fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+            return exitfailure
+        }
+    }
+
+    if err := cut(stdin,
+                  stdout,
+                  stderr,
+                  verbose,
+                  flagset.Args()[0],
+                  starttime,
+                  endtime,
+                  *flago,
+                  *flagn); err != nil {
+        fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+        return exitfailure
+    }
+
+    return exitsuccess
+}
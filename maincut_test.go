@@ -0,0 +1,198 @@
+// 'maincut_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "testing"
+)
+
+
+func Test_parsetime(t *testing.T) {
+    tests := []struct {
+        s string
+        time int
+    }{
+        { "0", 0 },
+        { "1.5", 1500 },
+        { "0.0005", 1 },
+        { "2:03", 123000 },
+        { "1:02:03.25", 3723250 },
+        { "90", 90000 },
+    }
+    for _, test := range tests {
+        if time, err := parsetime(test.s); ! (err == nil && time == test.time) {
+            t.Errorf("Test_parsetime:  failed, %s", test.s)
+            return
+        }
+    }
+
+    for _, s := range []string{ "", "1:", ":30", "1:2:3:4", "-1", "1.", "a" } {
+        if _, err := parsetime(s); err == nil {
+            t.Errorf("Test_parsetime:  failed, %s", s)
+            return
+        }
+    }
+}
+
+
+// Returns a 417 byte frame using maindatabegin bytes of main data from
+// earlier frames.
+func testmp3framemaindatabegin(maindatabegin int) []byte {
+    frame := testmp3frame()
+    copy(frame[4:], mp3sideinfobits(fmt.Sprintf("%09b", maindatabegin)))
+    return frame
+}
+
+
+// Returns an index of 417 byte frames, each using maindatabegin bytes of
+// main data from earlier frames.
+func testframeindex(t *testing.T, maindatabegins ...int) []*mp3frameindex {
+    frames := []*mp3frameindex{}
+    for j, maindatabegin := range maindatabegins {
+        m, err := newmp3headerfrombytes(testmp3framemaindatabegin(maindatabegin))
+        if err != nil {
+            t.Fatal(err)
+        }
+        frames = append(frames, &mp3frameindex{ offset: j * 417,
+                                                size: 417,
+                                                mp3header: m })
+    }
+    return frames
+}
+
+
+func Test_reservoirstart(t *testing.T) {
+    // Each frame has 417 - 36 bytes of main data.
+    frames := testframeindex(t, 0, 0, 0, 0, 500)
+    if first, ok := reservoirstart(frames, 4); ! (first == 2 && ok) {
+        t.Errorf("Test_reservoirstart:  failed")
+        return
+    }
+    if first, ok := reservoirstart(frames, 2); ! (first == 2 && ok) {
+        t.Errorf("Test_reservoirstart:  failed")
+        return
+    }
+
+    // Not enough frames before.
+    frames = testframeindex(t, 0, 500)
+    if first, ok := reservoirstart(frames, 1); ! (first == 0 && !ok) {
+        t.Errorf("Test_reservoirstart:  failed")
+        return
+    }
+
+    // A Xing frame is not audio.
+    frames = testframeindex(t, 0, 0, 500)
+    frames[1].xing = &xing{}
+    if first, ok := reservoirstart(frames, 2); ! (first == 2 && !ok) {
+        t.Errorf("Test_reservoirstart:  failed")
+        return
+    }
+}
+
+
+func Test_mp3adoracuthandler(t *testing.T) {
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    tag := i.bytes()
+
+    frames := [][]byte{}
+    stream := append([]byte{}, tag...)
+    for j := 0; j < 4; j++ {
+        frame := testmp3frame()
+        // Granule 0, channel 0, part23length of 1000.
+        copy(frame[4:], mp3sideinfobits("000000000 000 0000 0000 001111101000"))
+        frame[100] = byte(j)
+        frames = append(frames, frame)
+        stream = append(stream, frame...)
+    }
+
+    var buffer bytes.Buffer
+    h := newmp3adoracuthandler(&buffer, 1, 2, 3, []byte("Xing"), false, nil)
+    if _, err := newmp3adora(h).parse(bytes.NewReader(stream)); err != nil {
+        t.Errorf("Test_mp3adoracuthandler:  failed")
+        return
+    }
+
+    out := buffer.Bytes()
+    if ! (len(out) == 4 + 417 + 417 &&
+          string(out[0:4]) == "Xing" &&
+          out[4 + 100] == 1 &&
+          bytes.Equal(out[4 + 417:], frames[2])) {
+        t.Errorf("Test_mp3adoracuthandler:  failed")
+        return
+    }
+
+    // The frame before start is silenced.
+    m, err := newmp3headerfrombytes(out[4:4 + 417])
+    if ! (err == nil &&
+          m.sideinfo.granules[0][0].part23length == 0) {
+        t.Errorf("Test_mp3adoracuthandler:  failed")
+        return
+    }
+}
+
+
+// The tags are copied without the frames giving times.
+func Test_cut(t *testing.T) {
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    i.setframe(newid3v2textframe("TLEN", "261"))
+    i.setframe((&id3v2chap{ elementid: "chp0",
+                            endtime: 261,
+                            startoffset: id3v2chapnooffset,
+                            endoffset: id3v2chapnooffset }).frame(4))
+    stream := i.bytes()
+    for j := 0; j < 10; j++ {
+        stream = append(stream, testmp3frame()...)
+    }
+
+    file := testfile(t, stream)
+    defer os.Remove(file.Name())
+    file.Close()
+    fileout := file.Name() + ".out"
+    defer os.Remove(fileout)
+    stdout := testfile(t, nil)
+    defer os.Remove(stdout.Name())
+    defer stdout.Close()
+
+    // Each frame is 1152 / 44100 seconds, so the cut starts at frame 2.
+    err := cut(nil, stdout, stdout, false, file.Name(), 50, -1, fileout, false)
+    if err != nil {
+        t.Errorf("Test_cut:  failed, %s", err)
+        return
+    }
+
+    file1, err := os.Open(fileout)
+    if err != nil {
+        t.Errorf("Test_cut:  failed")
+        return
+    }
+    defer file1.Close()
+
+    i1, _, err := readleadingid3v2(file1)
+    file1.Seek(0, io.SeekStart)
+    parsed, err1 := testparse(file1)
+    if ! (err == nil && err1 == nil &&
+          i1.frame("TIT2").text() == "Title" &&
+          i1.frame("TLEN") == nil &&
+          i1.frame("CHAP") == nil &&
+          len(parsed) == 1 + 1 + 8 &&
+          parsed[len(parsed) - 1] == "mp3frame 417") {
+        t.Errorf("Test_cut:  failed, %v", parsed)
+        return
+    }
+
+    output, _ := ioutil.ReadFile(stdout.Name())
+    if ! bytes.Contains(output, []byte("Removing CHAP frames\n")) {
+        t.Errorf("Test_cut:  failed")
+        return
+    }
+}
@@ -0,0 +1,116 @@
+// 'mainexportlrc.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path"
+    "strings"
+)
+
+
+// Write the first SYLT frame with lyrics to '<filename>.lrc'.  Timestamps in
+// mpeg frames are converted to milliseconds using the first mp3 frame.
+func exportlrc(stdin *os.File,
+               stdout *os.File,
+               stderr *os.File,
+               verbose bool,
+               filename string,
+               dryrun bool) (err error) {
+    h, err := collect(filename)
+    if err != nil {
+        return err
+    }
+
+    for _, i := range h.id3v2s {
+        for _, f := range i.frames {
+            s, err := newid3v2syltfromframe(f)
+            if err != nil || s.contenttype != id3v2syltcontenttypelyrics {
+                continue
+            }
+
+            lines := s.lines
+            if s.timestampformat == id3v2sylttimestampmpegframes {
+                if h.mp3header == nil {
+                    return fmt.Errorf("Unable to find mp3 frame for timestamps.")
+                }
+                lines = []id3v2syltline{}
+                for _, line := range s.lines {
+                    line.timestamp =
+                        int(float64(line.timestamp) * h.mp3header.duration())
+                    lines = append(lines, line)
+                }
+            }
+
+            filenamelrc := strings.TrimSuffix(filename, path.Ext(filename)) +
+                           ".lrc"
+            fmt.Fprintf(stdout,
+                        "Writing %s %d lines\n",
+                        filenamelrc,
+                        len(lines))
+            if dryrun {
+                return nil
+            }
+
+            return ioutil.WriteFile(filenamelrc, []byte(formatlrc(lines)), 0644)
+        }
+    }
+
+    fmt.Fprintf(stdout, "Skipping, no synchronised lyrics\n")
+    return nil
+}
+
+
+func mainexportlrc(stdin *os.File,
+                   stdout *os.File,
+                   stderr *os.File,
+                   verbose bool,
+                   args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("exportlrc", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] exportlrc [ options ] filename ...")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) == 0 {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    for i, filename := range flagset.Args() {
+        if i > 0 {
+            fmt.Fprintln(stdout)
+        }
+        fmt.Fprintf(stdout, "%s:\n", filename)
+
+        if err := exportlrc(stdin,
+                            stdout,
+                            stderr,
+                            verbose,
+                            filename,
+                            *flagn); err != nil {
+            fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+            return exitfailure
+        }
+    }
+
+    return exitsuccess
+}
@@ -0,0 +1,191 @@
+// 'mainextractart.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "crypto/sha1"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path"
+    "strings"
+)
+
+
+func collect(filename string) (h *mp3adoracollecthandler, err error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    h = newmp3adoracollecthandler()
+    mp3adora := newmp3adora(h)
+    if _, err = mp3adora.parse(file); err != nil {
+        return nil, err
+    }
+
+    return h, nil
+}
+
+
+// Pictures are written to '<prefix><picture type>.<extension>', with a
+// number added for further pictures of the same type.  Identical pictures
+// are only written once, seen maps sha1 to the filename written.
+func extractart(stdout *os.File,
+                verbose bool,
+                filename string,
+                prefix string,
+                seen map[[sha1.Size]byte]string,
+                dryrun bool) (err error) {
+    h, err := collect(filename)
+    if err != nil {
+        return err
+    }
+
+    for _, a := range h.pictures() {
+        sum := sha1.Sum(a.data)
+        if filenameart, ok := seen[sum]; ok {
+            if verbose {
+                fmt.Fprintf(stdout,
+                            "Skipping %s, same as %s\n",
+                            id3v2apicpicturetype(a.picturetype),
+                            filenameart)
+            }
+            continue
+        }
+
+        mimetype := imagemimetype(a.data)
+        if mimetype == "" {
+            mimetype = a.mimetype
+        }
+        extension, ok := imageextensions[strings.ToLower(mimetype)]
+        if !ok {
+            extension = ".bin"
+        }
+
+        base := prefix + id3v2apicpicturetype(a.picturetype)
+        filenameart := base + extension
+        for n := 2; written(seen, filenameart); n++ {
+            filenameart = fmt.Sprintf("%s %d%s", base, n, extension)
+        }
+
+        seen[sum] = filenameart
+        fmt.Fprintf(stdout,
+                    "Writing %s %s %d bytes\n",
+                    filenameart,
+                    mimetype,
+                    len(a.data))
+        if dryrun {
+            continue
+        }
+
+        if err = ioutil.WriteFile(filenameart, a.data, 0644); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+
+func written(seen map[[sha1.Size]byte]string, filename string) bool {
+    for _, filename1 := range seen {
+        if filename1 == filename {
+            return true
+        }
+    }
+    return false
+}
+
+
+func extractartalbum(stdout *os.File,
+                     verbose bool,
+                     directorypath string,
+                     dryrun bool) (err error) {
+    fileinfos, err := ioutil.ReadDir(directorypath)
+    if err != nil {
+        return err
+    }
+
+    seen := map[[sha1.Size]byte]string{}
+    for _, fileinfo := range fileinfos {
+        if path.Ext(fileinfo.Name()) != ".mp3" {
+            continue
+        }
+        fmt.Fprintf(stdout, "Processing %s\n", fileinfo.Name())
+
+        if err = extractart(stdout,
+                            verbose,
+                            path.Join(directorypath, fileinfo.Name()),
+                            path.Clean(directorypath) + "/",
+                            seen,
+                            dryrun); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+
+func mainextractart(stdin *os.File,
+                    stdout *os.File,
+                    stderr *os.File,
+                    verbose bool,
+                    args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("extractart", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] extractart [ options ] filename|directory ...")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) == 0 {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    for i, filename := range flagset.Args() {
+        if i > 0 {
+            fmt.Fprintln(stdout)
+        }
+        fmt.Fprintf(stdout, "%s:\n", filename)
+
+        fileinfo, err := os.Stat(filename)
+        if err == nil {
+            if fileinfo.IsDir() {
+                err = extractartalbum(stdout, verbose, filename, *flagn)
+            } else {
+                err = extractart(stdout,
+                                 verbose,
+                                 filename,
+                                 strings.TrimSuffix(filename,
+                                                    path.Ext(filename)) + " - ",
+                                 map[[sha1.Size]byte]string{},
+                                 *flagn)
+            }
+        }
+        if err != nil {
+            fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+            return exitfailure
+        }
+    }
+
+    return exitsuccess
+}
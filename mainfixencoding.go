@@ -0,0 +1,277 @@
+// 'mainfixencoding.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+
+    "golang.org/x/text/encoding"
+)
+
+
+// Returns the encoding of s, either e or the likeliest encoding if e is nil,
+// or nil if s is ASCII.  Unless latin1, nil is also returned if the likeliest
+// encoding is ISO-8859-1 or windows-1252, a superset of it, as ISO-8859-1
+// text is then already right.
+func fixencodingguess(e encoding.Encoding,
+                      encodingname string,
+                      s string,
+                      latin1 bool) (e1 encoding.Encoding, name string) {
+    guesses := detectencoding(s)
+    if len(guesses) == 0 {
+        return nil, ""
+    }
+    if e != nil {
+        return e, encodingname
+    }
+    if !latin1 &&
+       (guesses[0].name == "iso8859-1" || guesses[0].name == "windows-1252") {
+        return nil, ""
+    }
+    return guesses[0].encoding, guesses[0].name
+}
+
+
+// Reinterpret the ISO-8859-1 text frames of i in the given encoding, or in the
+// likeliest encoding if e is nil, as UTF-8 frames, returning whether any
+// changed.
+func fixencodingframes(stdout io.Writer,
+                       i *id3v2,
+                       e encoding.Encoding,
+                       encodingname string) (changed bool, err error) {
+    for j, f := range i.frames {
+        texts := f.latin1texts()
+        e1, name := fixencodingguess(e,
+                                     encodingname,
+                                     strings.Join(texts, " "),
+                                     false)
+        if e1 == nil {
+            continue
+        }
+
+        texts1 := make([]string, len(texts))
+        for k, text := range texts {
+            if texts1[k], err = decode(e1, text); err != nil {
+                return changed, err
+            }
+        }
+
+        fmt.Fprintf(stdout,
+                    "    %s:  %s  ->  %s  (%s)\n",
+                    f.id,
+                    f.text(),
+                    strings.Join(texts1, "/"),
+                    name)
+
+        f1 := newid3v2textframe(f.id, texts1...)
+        f1.flags = f.flags
+        f1.group = f.group
+        i.frames[j] = f1
+        changed = true
+    }
+
+    return changed, nil
+}
+
+
+// Reinterpret ISO-8859-1 id3v2 text frames, and id3v1 strings, in the given
+// encoding, or in the likeliest encoding if encodingname is "", and rewrite
+// them as UTF-8 id3v2.4 frames.  Id3v1 strings are only used for frames the
+// id3v2 tag does not have, and the id3v1 tag itself is left unchanged.
+func fixencoding(stdin *os.File,
+                 stdout *os.File,
+                 stderr *os.File,
+                 verbose bool,
+                 filename string,
+                 encodingname string,
+                 padding int,
+                 dryrun bool) (err error) {
+    var e encoding.Encoding
+    if encodingname != "" {
+        if e, err = find(encodingname); err != nil {
+            return err
+        }
+    }
+
+    file, err := os.OpenFile(filename, os.O_RDWR, 0)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    id3v2, size, err := readleadingid3v2(file)
+    if err != nil {
+        return err
+    }
+    if id3v2 == nil {
+        id3v2 = newid3v2()
+    }
+
+    id3v1, err := readtrailingid3v1(file)
+    if err != nil {
+        return err
+    }
+
+    changed, err := fixencodingframes(stdout, id3v2, e, encodingname)
+    if err != nil {
+        return err
+    }
+
+    if id3v1 != nil {
+        texts := []string{}
+        for _, s := range id3v1.strings() {
+            texts = append(texts, *s)
+        }
+        // Note id3v1 strings are as read from the tag, so are decoded even
+        // if ISO-8859-1.
+        e1, name := fixencodingguess(e,
+                                     encodingname,
+                                     strings.Join(texts, " "),
+                                     true)
+        if e1 != nil {
+            if err = id3v1.decode(e1); err != nil {
+                return err
+            }
+        }
+
+        items := []struct {
+            name string
+            id string
+            value string
+        }{
+            { "title", "TIT2", id3v1.title },
+            { "artist", "TPE1", id3v1.artist },
+            { "album", "TALB", id3v1.album },
+            { "year", "TDRC", id3v1.year },
+            { "comment", "COMM", id3v1.comment },
+            { "track", "TRCK", "" },
+        }
+        if id3v1.track != 0 {
+            items[5].value = strconv.Itoa(int(id3v1.track))
+        }
+
+        for _, item := range items {
+            if item.value == "" || id3v2.frame(item.id) != nil ||
+               item.id == "TDRC" && id3v2.frame("TYER") != nil {
+                continue
+            }
+
+            if name != "" {
+                fmt.Fprintf(stdout,
+                            "    id3v1 %s -> %s:  %s  (%s)\n",
+                            item.name,
+                            item.id,
+                            item.value,
+                            name)
+            } else {
+                fmt.Fprintf(stdout,
+                            "    id3v1 %s -> %s:  %s\n",
+                            item.name,
+                            item.id,
+                            item.value)
+            }
+
+            if item.id == "COMM" {
+                id3v2.frames = append(id3v2.frames,
+                                      newid3v2commframe(item.value))
+            } else {
+                id3v2.frames = append(id3v2.frames,
+                                      newid3v2textframe(item.id, item.value))
+            }
+            changed = true
+        }
+    }
+
+    if !changed {
+        fmt.Fprintf(stdout, "Nothing to fix\n")
+        return nil
+    }
+
+    if err = upgradeid3v2(stdout, id3v2); err != nil {
+        return err
+    }
+
+    if dryrun {
+        return nil
+    }
+
+    if size > 0 && id3v2fitsinplace(id3v2, size) {
+        if verbose {
+            fmt.Fprintf(stdout, "Updating tags in place\n")
+        }
+        _, err = writeid3v2inplace(file, id3v2, size)
+        return err
+    }
+
+    if verbose {
+        fmt.Fprintf(stdout, "Rewriting file\n")
+    }
+    id3v2.footer = false
+    id3v2.padding = padding
+    return replaceleadingid3v2(filename, id3v2, size)
+}
+
+
+func mainfixencoding(stdin *os.File,
+                     stdout *os.File,
+                     stderr *os.File,
+                     verbose bool,
+                     args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("fixencoding", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] fixencoding [ options ] filename ...")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagencoding := flagset.String("encoding",
+                                   "",
+                                   "Encoding of the tags, otherwise detected for each tag and frame")
+    flagpadding := flagset.Int("padding",
+                               1024,
+                               "Id3v2 padding in bytes when rewriting")
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) == 0 {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    for i, filename := range flagset.Args() {
+        if i > 0 {
+            fmt.Fprintln(stdout)
+        }
+        fmt.Fprintf(stdout, "%s:\n", filename)
+
+        if err := fixencoding(stdin,
+                              stdout,
+                              stderr,
+                              verbose,
+                              filename,
+                              *flagencoding,
+                              *flagpadding,
+                              *flagn); err != nil {
+            fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+            return exitfailure
+        }
+    }
+
+    return exitsuccess
+}
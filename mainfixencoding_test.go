@@ -0,0 +1,71 @@
+// 'mainfixencoding_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io/ioutil"
+    "testing"
+)
+
+
+func Test_fixencodingguess(t *testing.T) {
+    e1251, _ := find("windows-1251")
+    e1252, _ := find("windows-1252")
+    latin1, _ := convert(e1252, "Café del Mar Señor", '?')
+    cyrillic, _ := convert(e1251, "Звезда по имени Солнце", '?')
+
+    // ISO-8859-1 text is left alone, unless asked.
+    e, _ := fixencodingguess(nil, "", latin1, false)
+    e1, name1 := fixencodingguess(nil, "", latin1, true)
+    e2, name2 := fixencodingguess(nil, "", cyrillic, false)
+    e3, name3 := fixencodingguess(e1251, "windows-1251", latin1, false)
+    e4, _ := fixencodingguess(e1251, "windows-1251", "Abbey Road", false)
+    if ! (e == nil &&
+          e1 != nil && name1 == "windows-1252" &&
+          e2 == e1251 && name2 == "windows-1251" &&
+          e3 == e1251 && name3 == "windows-1251" &&
+          e4 == nil) {
+        t.Errorf("Test_fixencodingguess:  failed")
+        return
+    }
+}
+
+
+// Replaced frames keep their flags and group.
+func Test_fixencodingframes(t *testing.T) {
+    e1251, _ := find("windows-1251")
+    e1252, _ := find("windows-1252")
+    latin1, _ := convert(e1252, "Café del Mar", '?')
+    cyrillic, _ := convert(e1251, "Звезда по имени Солнце", '?')
+
+    i := &id3v2{ version: 4 }
+    i.setframe(&id3v2frame{ id: "TIT2",
+                            flags: id3v24frameflaggrouping,
+                            group: 7,
+                            data: []byte("\x00" + cyrillic) })
+    i.setframe(&id3v2frame{ id: "TALB",
+                            data: []byte("\x00" + latin1) })
+
+    changed, err := fixencodingframes(ioutil.Discard, i, nil, "")
+    f := i.frame("TIT2")
+    if ! (changed && err == nil &&
+          f.text() == "Звезда по имени Солнце" &&
+          f.flags == id3v24frameflaggrouping &&
+          f.group == 7 &&
+          string(i.frame("TALB").data) == "\x00" + latin1) {
+        t.Errorf("Test_fixencodingframes:  failed")
+        return
+    }
+
+    // The tag round trips with the group.
+    i1, err := newid3v2frombytes(i.bytes())
+    if ! (err == nil &&
+          i1.frame("TIT2").group == 7 &&
+          i1.frame("TIT2").text() == "Звезда по имени Солнце") {
+        t.Errorf("Test_fixencodingframes:  failed")
+        return
+    }
+}
@@ -0,0 +1,165 @@
+// 'mainfixvbr.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+)
+
+
+// Add a Xing header to filename, or replace its Xing header, with the number
+// of frames and bytes and table of contents from its mp3 frames.  The quality
+// and LAME extension of an existing Xing header are kept.  As for LAME, a CBR
+// file gets an 'Info' header rather than 'Xing'.  Everything else in the file
+// is kept as it is.
+func fixvbr(stdin *os.File,
+            stdout *os.File,
+            stderr *os.File,
+            verbose bool,
+            filename string,
+            dryrun bool) (err error) {
+    file, err := os.OpenFile(filename, os.O_RDWR, 0)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    h := newmp3adoraframeindexhandler()
+    mp3adora := newmp3adora(h)
+    if _, err = mp3adora.parse(file); err != nil {
+        return err
+    }
+
+    first := 0
+    var original *xing
+    if len(h.frames) > 0 && h.frames[0].xing != nil {
+        first = 1
+        original = h.frames[0].xing
+    }
+    if first >= len(h.frames) {
+        return fmt.Errorf("Unable to find mp3 frames in %s", filename)
+    }
+
+    headers := []*mp3header{}
+    for _, f := range h.frames[first:] {
+        headers = append(headers, f.mp3header)
+    }
+
+    // Note an existing Xing frame is kept the same size where possible, so
+    // that it can be updated in place.
+    template := make([]byte, 4)
+    if _, err = file.ReadAt(template, int64(h.frames[0].offset)); err != nil {
+        return err
+    }
+    xing, err := newxingframe(template, headers, original)
+    if err != nil {
+        return err
+    }
+    x, err := newxingfromframe(xing)
+    if err != nil {
+        return err
+    }
+
+    if original == nil {
+        fmt.Fprintf(stdout,
+                    "    none  ->  %s:  %d frames, %d bytes\n",
+                    x.name,
+                    x.frames,
+                    x.bytes)
+    } else if original.frames == x.frames &&
+              original.bytes == x.bytes &&
+              bytes.Equal(original.toc, x.toc) {
+        fmt.Fprintf(stdout, "Nothing to fix\n")
+        return nil
+    } else {
+        fmt.Fprintf(stdout,
+                    "    %s:  %d frames, %d bytes  ->  %s:  %d frames, %d bytes\n",
+                    original.name,
+                    original.frames,
+                    original.bytes,
+                    x.name,
+                    x.frames,
+                    x.bytes)
+    }
+
+    if dryrun {
+        return nil
+    }
+
+    if original != nil && h.frames[0].size == len(xing) {
+        if verbose {
+            fmt.Fprintf(stdout, "Updating xing header in place\n")
+        }
+        _, err = file.WriteAt(xing, int64(h.frames[0].offset))
+        return err
+    }
+
+    if verbose {
+        fmt.Fprintf(stdout, "Rewriting file\n")
+    }
+    return rewritefile(filename,
+                       dryrun,
+                       func(out io.Writer) mp3adorahandler {
+                           return newmp3adoraxingrewritehandler(out,
+                                                                xing,
+                                                                original != nil)
+                       })
+}
+
+
+func mainfixvbr(stdin *os.File,
+                stdout *os.File,
+                stderr *os.File,
+                verbose bool,
+                args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("fixvbr", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] fixvbr [ options ] filename ...")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "CBR files get an Info header rather than a Xing header, as from LAME.")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) == 0 {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    for i, filename := range flagset.Args() {
+        if i > 0 {
+            fmt.Fprintln(stdout)
+        }
+        fmt.Fprintf(stdout, "%s:\n", filename)
+
+        if err := fixvbr(stdin,
+                         stdout,
+                         stderr,
+                         verbose,
+                         filename,
+                         *flagn); err != nil {
+            fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+            return exitfailure
+        }
+    }
+
+    return exitsuccess
+}
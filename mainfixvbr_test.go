@@ -0,0 +1,105 @@
+// 'mainfixvbr_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "strings"
+    "testing"
+)
+
+
+// Runs fixvbr on bytes, returning the new contents and the output.
+func testfixvbr(t *testing.T, stream []byte) (bytes1 []byte, output string) {
+    file := testfile(t, stream)
+    defer os.Remove(file.Name())
+    file.Close()
+    stdout := testfile(t, nil)
+    defer os.Remove(stdout.Name())
+    defer stdout.Close()
+
+    if err := fixvbr(nil, stdout, stdout, true, file.Name(), false); err != nil {
+        t.Errorf("testfixvbr:  failed, %s", err)
+    }
+
+    bytes1, _ = ioutil.ReadFile(file.Name())
+    bytes2, _ := ioutil.ReadFile(stdout.Name())
+    return bytes1, string(bytes2)
+}
+
+
+// Without a Xing frame the file is rewritten, keeping everything else.
+func Test_fixvbrrewrite(t *testing.T) {
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    tag := i.bytes()
+    frame1 := testmp3frame()
+    frame1[2] = 0xb0
+    frame1 = append(frame1, make([]byte, 626 - 417)...)
+
+    rest := []byte{}
+    rest = append(rest, testmp3frame()...)
+    rest = append(rest, frame1...)
+    rest = append(rest, "junk"...)
+    rest = append(rest, testmp3frame()...)
+    rest = append(rest, newid3v1fromitems("Title", "", "", "", "", 0, 0).bytes()...)
+    stream := append(append([]byte{}, tag...), rest...)
+
+    bytes1, output := testfixvbr(t, stream)
+    x, err := newxingfromframe(bytes1[len(tag):])
+    if ! (err == nil &&
+          strings.Contains(output, "Rewriting file\n") &&
+          x.name == "Xing" &&
+          x.frames == 3 &&
+          x.bytes == len(bytes1) - len(tag) - 4 - 128 &&
+          bytes.Equal(bytes1[0:len(tag)], tag) &&
+          bytes.Equal(bytes1[len(bytes1) - len(rest):], rest)) {
+        t.Errorf("Test_fixvbrrewrite:  failed, %s", output)
+        return
+    }
+
+    // The second time there is nothing to fix.
+    bytes2, output := testfixvbr(t, bytes1)
+    if ! (strings.Contains(output, "Nothing to fix\n") &&
+          bytes.Equal(bytes1, bytes2)) {
+        t.Errorf("Test_fixvbrrewrite:  failed, %s", output)
+        return
+    }
+}
+
+
+// A Xing frame the same size is updated in place, keeping the quality and
+// LAME extension.
+func Test_fixvbrinplace(t *testing.T) {
+    m, _ := newmp3headerfrombytes(testmp3frame())
+    original := &xing{ quality: 78, lame: make([]byte, xinglamesize) }
+    copy(original.lame, "LAME3.100")
+    xing, _ := newxingframe(testmp3frame()[0:4],
+                            []*mp3header{ m, m, m, m },
+                            original)
+
+    stream := append([]byte{}, xing...)
+    for j := 0; j < 3; j++ {
+        stream = append(stream, testmp3frame()...)
+    }
+
+    bytes1, output := testfixvbr(t, stream)
+    x, err := newxingfromframe(bytes1)
+    if ! (err == nil &&
+          strings.Contains(output, "Updating xing header in place\n") &&
+          len(bytes1) == len(stream) &&
+          x.name == "Info" &&
+          x.frames == 3 &&
+          x.bytes == len(stream) &&
+          x.quality == 78 &&
+          string(x.lame[0:9]) == "LAME3.100" &&
+          bytes.Equal(bytes1[len(xing):], stream[len(xing):])) {
+        t.Errorf("Test_fixvbrinplace:  failed, %s", output)
+        return
+    }
+}
@@ -0,0 +1,296 @@
+// 'mainjoin.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+)
+
+
+// An mp3 file to join, with the index of its first audio frame, i.e. after
+// any Xing frame, and of its first frame which does not use main data from
+// before first, the bit reservoir.
+type joinfile struct {
+    filename string
+    h *mp3adoraframeindexhandler
+    first int
+    start int
+}
+
+
+func newjoinfile(filename string) (j *joinfile, err error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    j = &joinfile{ filename: filename,
+                   h: newmp3adoraframeindexhandler() }
+    mp3adora := newmp3adora(j.h)
+    if _, err = mp3adora.parse(file); err != nil {
+        return nil, err
+    }
+
+    if len(j.h.frames) > 0 && j.h.frames[0].xing != nil {
+        j.first = 1
+    }
+    if j.first >= len(j.h.frames) {
+        return nil, fmt.Errorf("Unable to find mp3 frames in %s", filename)
+    }
+
+    // Note main_data_begin is at most 511 bytes.
+    j.start = j.first
+    available := 0
+    for k := j.first; k < len(j.h.frames) && available < 512; k++ {
+        m := j.h.frames[k].mp3header
+        if m.sideinfo == nil {
+            break
+        }
+        if m.sideinfo.maindatabegin > available {
+            j.start = k + 1
+        }
+        available += j.h.frames[k].size - m.maindataoffset()
+    }
+
+    return j, nil
+}
+
+
+// Whether the audio frames of j can follow those of j1 in one stream.
+func (j *joinfile) compatible(j1 *joinfile) (err error) {
+    m := j.h.frames[j.first].mp3header
+    m1 := j1.h.frames[j1.first].mp3header
+
+    if m.audioversion != m1.audioversion ||
+       m.layer != m1.layer ||
+       m.samplingrate != m1.samplingrate ||
+       m.channels() != m1.channels() {
+        return fmt.Errorf("Unable to join %s, MPEG %1.1f layer %d %d Hz %d channels, to %s, MPEG %1.1f layer %d %d Hz %d channels",
+                          j.filename,
+                          m.audioversion,
+                          m.layer,
+                          m.samplingrate,
+                          m.channels(),
+                          j1.filename,
+                          m1.audioversion,
+                          m1.layer,
+                          m1.samplingrate,
+                          m1.channels())
+    }
+
+    return nil
+}
+
+
+// Join the audio frames of filenames into one stream in filenameout, with a
+// new Xing header and the tags of filenametags.  Frames at the start of each
+// file which use main data from before it, the bit reservoir, are silenced.
+func join(stdin *os.File,
+          stdout *os.File,
+          stderr *os.File,
+          verbose bool,
+          filenames []string,
+          filenametags string,
+          filenameout string,
+          dryrun bool) (err error) {
+    joinfiles := []*joinfile{}
+    headers := []*mp3header{}
+    duration := 0.0
+
+    for _, filename := range append([]string{ filenametags }, filenames...) {
+        if filename == filenameout {
+            return fmt.Errorf("Unable to join %s to itself", filename)
+        }
+    }
+
+    for _, filename := range filenames {
+        j, err := newjoinfile(filename)
+        if err != nil {
+            return err
+        }
+        if len(joinfiles) > 0 {
+            if err = j.compatible(joinfiles[0]); err != nil {
+                return err
+            }
+        }
+        joinfiles = append(joinfiles, j)
+
+        for _, f := range j.h.frames[j.first:] {
+            headers = append(headers, f.mp3header)
+        }
+
+        fmt.Fprintf(stdout,
+                    "%s:  %d frames, %s\n",
+                    filename,
+                    len(j.h.frames) - j.first,
+                    formatchaptertime(int(j.h.duration())))
+        if verbose && j.start > j.first {
+            fmt.Fprintf(stdout,
+                        "Silencing %d frames for the bit reservoir\n",
+                        j.start - j.first)
+        }
+        duration += j.h.duration()
+    }
+
+    fmt.Fprintf(stdout,
+                "Joining %d frames, %s\n",
+                len(headers),
+                formatchaptertime(int(duration)))
+
+    j := joinfiles[0]
+    template := make([]byte, 4)
+    if err = readat(j.filename, template, j.h.frames[j.first].offset); err != nil {
+        return err
+    }
+    xing, err := newxingframe(template, headers, nil)
+    if err != nil {
+        return err
+    }
+
+    if dryrun {
+        return nil
+    }
+
+    fileout, err := os.Create(filenameout)
+    if err != nil {
+        return err
+    }
+    defer fileout.Close()
+
+    write := func(filename string, h mp3adorahandler) (err error) {
+        if err = parsefile(filename, h); err != nil {
+            os.Remove(filenameout)
+        }
+        return err
+    }
+
+    err = write(filenametags, newmp3adoratagcopyhandler(fileout, false))
+    if err != nil {
+        return err
+    }
+
+    for _, j := range joinfiles {
+        err = write(j.filename, newmp3adoracuthandler(fileout,
+                                                      j.first,
+                                                      j.start,
+                                                      len(j.h.frames),
+                                                      xing,
+                                                      false,
+                                                      nil))
+        if err != nil {
+            return err
+        }
+        xing = nil
+    }
+
+    err = write(filenametags, newmp3adoratagcopyhandler(fileout, true))
+    if err != nil {
+        return err
+    }
+
+    if err = fileout.Close(); err != nil {
+        os.Remove(filenameout)
+        return err
+    }
+
+    return nil
+}
+
+
+// Parse the options in args, which may also follow the filenames, and
+// return the filenames.  Everything after '--' is a filename.
+func parsefilenames(flagset *flag.FlagSet, args []string) (filenames []string) {
+    for flagset.Parse(args); len(flagset.Args()) > 0; flagset.Parse(args) {
+        if optionsended(flagset, args[:len(args) - len(flagset.Args())]) {
+            return append(filenames, flagset.Args()...)
+        }
+        filenames = append(filenames, flagset.Arg(0))
+        args = flagset.Args()[1:]
+    }
+    return filenames
+}
+
+
+// Whether the args consumed by flagset.Parse() end with '--' ending the
+// options, rather than with '--' as the value of an option.
+func optionsended(flagset *flag.FlagSet, args []string) bool {
+    for j := 0; j < len(args); j++ {
+        if args[j] == "--" {
+            return j == len(args) - 1
+        }
+        name := strings.TrimLeft(args[j], "-")
+        if strings.Contains(name, "=") {
+            continue
+        }
+        f := flagset.Lookup(name)
+        if f == nil {
+            continue
+        }
+        if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+            j++
+        }
+    }
+    return false
+}
+
+
+func mainjoin(stdin *os.File,
+              stdout *os.File,
+              stderr *os.File,
+              verbose bool,
+              args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("join", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] join [ options ] filename ... -o outfilename")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagtags := flagset.String("tags",
+                               "",
+                               "Filename to copy tags from, otherwise the first file")
+    flago := flagset.String("o",
+                            "",
+                            "Output filename")
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    filenames := parsefilenames(flagset, args)
+
+    if len(filenames) == 0 || *flago == "" {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    filenametags := *flagtags
+    if filenametags == "" {
+        filenametags = filenames[0]
+    }
+
+    if err := join(stdin,
+                   stdout,
+                   stderr,
+                   verbose,
+                   filenames,
+                   filenametags,
+                   *flago,
+                   *flagn); err != nil {
+        fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+        return exitfailure
+    }
+
+    return exitsuccess
+}
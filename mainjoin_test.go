@@ -0,0 +1,152 @@
+// 'mainjoin_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "reflect"
+    "testing"
+)
+
+
+func Test_newjoinfile(t *testing.T) {
+    // Each frame has 417 - 36 bytes of main data, so the frame using 500
+    // bytes needs both frames before it.  Later frames are not considered
+    // once 511 bytes are available.
+    stream := []byte{}
+    for _, maindatabegin := range []int{ 0, 500, 0, 100, 0 } {
+        stream = append(stream, testmp3framemaindatabegin(maindatabegin)...)
+    }
+    file := testfile(t, stream)
+    defer os.Remove(file.Name())
+    file.Close()
+
+    j, err := newjoinfile(file.Name())
+    if ! (err == nil && j.first == 0 && j.start == 2) {
+        t.Errorf("Test_newjoinfile:  failed")
+        return
+    }
+
+    // A Xing frame is skipped.
+    m, _ := newmp3headerfrombytes(testmp3frame())
+    xing, _ := newxingframe(testmp3frame()[0:4],
+                            []*mp3header{ m, m },
+                            nil)
+    stream = append(append([]byte{}, xing...),
+                    testmp3framemaindatabegin(0)...)
+    stream = append(stream, testmp3framemaindatabegin(100)...)
+    file1 := testfile(t, stream)
+    defer os.Remove(file1.Name())
+    file1.Close()
+
+    j, err = newjoinfile(file1.Name())
+    if ! (err == nil && j.first == 1 && j.start == 1) {
+        t.Errorf("Test_newjoinfile:  failed")
+        return
+    }
+}
+
+
+func Test_joinfilecompatible(t *testing.T) {
+    joinfile := func(bytes4 []byte) *joinfile {
+        m, err := newmp3headerfrombytes(bytes4)
+        if err != nil {
+            t.Fatal(err)
+        }
+        h := newmp3adoraframeindexhandler()
+        h.frames = []*mp3frameindex{ &mp3frameindex{ mp3header: m } }
+        return &joinfile{ filename: fmt.Sprintf("%x", bytes4), h: h }
+    }
+
+    j := joinfile([]byte{ 0xff, 0xfb, 0x90, 0x00 })
+    if ! (j.compatible(joinfile([]byte{ 0xff, 0xfb, 0x90, 0x00 })) == nil &&
+          j.compatible(joinfile([]byte{ 0xff, 0xfb, 0xa0, 0x00 })) == nil) {
+        t.Errorf("Test_joinfilecompatible:  failed")
+        return
+    }
+
+    // Sampling rate, channels, layer and version.
+    for _, bytes4 := range [][]byte{ { 0xff, 0xfb, 0x94, 0x00 },
+                                     { 0xff, 0xfb, 0x90, 0xc0 },
+                                     { 0xff, 0xfd, 0x90, 0x00 },
+                                     { 0xff, 0xf3, 0x90, 0x00 } } {
+        if j.compatible(joinfile(bytes4)) == nil {
+            t.Errorf("Test_joinfilecompatible:  failed, %x", bytes4)
+            return
+        }
+    }
+}
+
+
+// The leading tags are those before the first mp3 frame and the trailing
+// tags those after it.
+func Test_mp3adoratagcopyhandler(t *testing.T) {
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    tag := i.bytes()
+    ape := testape()
+    id3v1 := newid3v1fromitems("Title", "", "", "", "", 0, 0).bytes()
+
+    stream := []byte{}
+    stream = append(stream, tag...)
+    stream = append(stream, ape...)
+    stream = append(stream, testmp3frame()...)
+    stream = append(stream, "junk"...)
+    stream = append(stream, testmp3frame()...)
+    stream = append(stream, id3v1...)
+
+    for _, trailing := range []bool{ false, true } {
+        var buffer bytes.Buffer
+        h := newmp3adoratagcopyhandler(&buffer, trailing)
+        _, err := newmp3adora(h).parse(bytes.NewReader(stream))
+        expected := append(append([]byte{}, tag...), ape...)
+        if trailing {
+            expected = id3v1
+        }
+        if ! (err == nil && bytes.Equal(buffer.Bytes(), expected)) {
+            t.Errorf("Test_mp3adoratagcopyhandler:  failed")
+            return
+        }
+    }
+}
+
+
+func Test_parsefilenames(t *testing.T) {
+    tests := []struct {
+        args []string
+        filenames []string
+        o string
+        n bool
+    }{
+        { []string{ "a", "b" }, []string{ "a", "b" }, "", false },
+        { []string{ "-n", "a", "-o", "out", "b" },
+          []string{ "a", "b" }, "out", true },
+        { []string{ "a", "b", "-o", "out" }, []string{ "a", "b" }, "out", false },
+        { []string{ "a", "--", "-o", "b", "--" },
+          []string{ "a", "-o", "b", "--" }, "", false },
+        { []string{ "-o", "--", "a", "-n" }, []string{ "a" }, "--", true },
+        { []string{ "-o=--", "--", "-n" }, []string{ "-n" }, "--", false },
+        { []string{ "a", "--" }, []string{ "a" }, "", false },
+    }
+    for _, test := range tests {
+        flagset := flag.NewFlagSet("test", flag.ContinueOnError)
+        flagset.SetOutput(ioutil.Discard)
+        o := flagset.String("o", "", "")
+        n := flagset.Bool("n", false, "")
+
+        filenames := parsefilenames(flagset, test.args)
+        if ! (reflect.DeepEqual(filenames, test.filenames) &&
+              *o == test.o &&
+              *n == test.n) {
+            t.Errorf("Test_parsefilenames:  failed, %v", test.args)
+            return
+        }
+    }
+}
@@ -0,0 +1,226 @@
+// 'mainnormalise.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "golang.org/x/text/unicode/norm"
+)
+
+
+// Returns frame normalised to form f as a UTF-8 frame, with its texts
+// before and after, or nil if it is already normalised or is not a text,
+// comment or lyrics frame.
+func normaliseframe(frame *id3v2frame,
+                    f norm.Form) (texts []string,
+                                  texts1 []string,
+                                  frame1 *id3v2frame) {
+    var newframe func(texts []string) *id3v2frame
+
+    // Note COMM has the same layout as USLT.
+    if frame.id == "COMM" || frame.id == "USLT" {
+        u, err := newid3v2usltfromframe(&id3v2frame{ id: "USLT",
+                                                     data: frame.data })
+        if err != nil {
+            return nil, nil, nil
+        }
+        texts = []string{ u.description, u.text }
+        newframe = func(texts []string) *id3v2frame {
+            u.textencoding = 3
+            u.description = texts[0]
+            u.text = texts[1]
+            frame1 := u.frame()
+            frame1.id = frame.id
+            return frame1
+        }
+    } else {
+        texts = frame.texts()
+        newframe = func(texts []string) *id3v2frame {
+            return newid3v2textframe(frame.id, texts...)
+        }
+    }
+
+    normalised := true
+    for _, text := range texts {
+        if !f.IsNormalString(text) {
+            normalised = false
+        }
+    }
+    if normalised {
+        return nil, nil, nil
+    }
+
+    texts1 = make([]string, len(texts))
+    for k, text := range texts {
+        texts1[k] = f.String(text)
+    }
+
+    frame1 = newframe(texts1)
+    frame1.flags = frame.flags
+    frame1.group = frame.group
+    return texts, texts1, frame1
+}
+
+
+// Report id3v2 text, comment and lyrics frames which are not in normalisation
+// form f, and rewrite them normalised as UTF-8 id3v2.4 frames.  Only files
+// needing changes are reported.  Returns whether filename needed changes.
+func normalise(stdin *os.File,
+               stdout *os.File,
+               stderr *os.File,
+               verbose bool,
+               filename string,
+               f norm.Form,
+               padding int,
+               dryrun bool) (changed bool, err error) {
+    file, err := os.OpenFile(filename, os.O_RDWR, 0)
+    if err != nil {
+        return false, err
+    }
+    defer file.Close()
+
+    id3v2, size, err := readleadingid3v2(file)
+    if err != nil || id3v2 == nil {
+        return false, err
+    }
+    if err = upgradeid3v2(stdout, id3v2); err != nil {
+        return false, err
+    }
+
+    for j, frame := range id3v2.frames {
+        texts, texts1, frame1 := normaliseframe(frame, f)
+        if frame1 == nil {
+            continue
+        }
+
+        if !changed {
+            fmt.Fprintf(stdout, "%s:\n", filename)
+        }
+        changed = true
+
+        fmt.Fprintf(stdout,
+                    "    %s:  %+q  ->  %+q\n",
+                    frame.id,
+                    strings.Join(texts, "/"),
+                    strings.Join(texts1, "/"))
+
+        id3v2.frames[j] = frame1
+    }
+
+    if !changed || dryrun {
+        return changed, nil
+    }
+
+    if id3v2fitsinplace(id3v2, size) {
+        if verbose {
+            fmt.Fprintf(stdout, "Updating tags in place\n")
+        }
+        _, err = writeid3v2inplace(file, id3v2, size)
+        return changed, err
+    }
+
+    if verbose {
+        fmt.Fprintf(stdout, "Rewriting file\n")
+    }
+    id3v2.footer = false
+    id3v2.padding = padding
+    return changed, replaceleadingid3v2(filename, id3v2, size)
+}
+
+
+func mainnormalise(stdin *os.File,
+                   stdout *os.File,
+                   stderr *os.File,
+                   verbose bool,
+                   args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("normalise", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] normalise [ options ] filename|directory ...")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Directories are searched for mp3 files recursively.")
+        fmt.Fprintln(stdout, "Id3v2 text, comment and lyrics frames are normalised, not filenames or")
+        fmt.Fprintln(stdout, "id3v1 tags.")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagform := flagset.String("form",
+                               "nfc",
+                               "Unicode normalisation form, nfc, nfd, nfkc or nfkd")
+    flagpadding := flagset.Int("padding",
+                               1024,
+                               "Id3v2 padding in bytes when rewriting")
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) == 0 {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    f, err := findnormalisationform(*flagform)
+    if err != nil {
+        fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+        return exitfailure
+    }
+
+    files := 0
+    fileschanged := 0
+
+    for _, filename := range flagset.Args() {
+        err := filepath.Walk(filename,
+                             func(filename string,
+                                  fileinfo os.FileInfo,
+                                  err error) error {
+            if err != nil {
+                return err
+            }
+            if fileinfo.IsDir() ||
+               strings.ToLower(filepath.Ext(filename)) != ".mp3" {
+                return nil
+            }
+
+            files++
+            changed, err := normalise(stdin,
+                                      stdout,
+                                      stderr,
+                                      verbose,
+                                      filename,
+                                      f,
+                                      *flagpadding,
+                                      *flagn)
+            if changed {
+                fileschanged++
+            }
+            return err
+        })
+        if err != nil {
+            fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+            return exitfailure
+        }
+    }
+
+    fmt.Fprintf(stdout,
+                "%d of %d files with text not in %s\n",
+                fileschanged,
+                files,
+                strings.ToUpper(*flagform))
+
+    return exitsuccess
+}
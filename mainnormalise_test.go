@@ -0,0 +1,52 @@
+// 'mainnormalise_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "testing"
+
+    "golang.org/x/text/unicode/norm"
+)
+
+
+func Test_normaliseframe(t *testing.T) {
+    _, texts1, f := normaliseframe(newid3v2textframe("TPE1", "Bjo\u0308rk"),
+                                   norm.NFC)
+    if ! (f != nil &&
+          texts1[0] == "Bj\u00f6rk" &&
+          f.text() == "Bj\u00f6rk") {
+        t.Errorf("Test_normaliseframe:  failed")
+        return
+    }
+
+    // Comments keep their language and description, and frames their flags.
+    u := &id3v2uslt{ textencoding: 3,
+                     language: "eng",
+                     description: "Cafe\u0301",
+                     text: "Bjo\u0308rk" }
+    comm := u.frame()
+    comm.id = "COMM"
+    comm.flags = id3v24frameflaggrouping
+    comm.group = 7
+    _, _, f = normaliseframe(comm, norm.NFC)
+    u1, err := newid3v2usltfromframe(&id3v2frame{ id: "USLT", data: f.data })
+    if ! (err == nil &&
+          f.id == "COMM" &&
+          f.flags == id3v24frameflaggrouping &&
+          f.group == 7 &&
+          u1.language == "eng" &&
+          u1.description == "Caf\u00e9" &&
+          u1.text == "Bj\u00f6rk") {
+        t.Errorf("Test_normaliseframe:  failed")
+        return
+    }
+
+    if _, _, f = normaliseframe(newid3v2textframe("TPE1", "Bj\u00f6rk"),
+                                norm.NFC); f != nil {
+        t.Errorf("Test_normaliseframe:  failed")
+        return
+    }
+}
@@ -0,0 +1,129 @@
+// 'mainresizeart.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+)
+
+
+// Resize the pictures in the APIC frames of i, returning the number resized.
+// Pictures which cannot be decoded are left as they are, with a warning.
+func resizeapics(stderr io.Writer,
+                 i *id3v2,
+                 maxsize int,
+                 maxbytes int,
+                 quality int) (n int) {
+    for j, f := range i.frames {
+        a, err := newid3v2apicfromframe(f)
+        if err != nil {
+            continue
+        }
+
+        resized, err := a.resize(maxsize, maxbytes, quality)
+        if err != nil {
+            fmt.Fprintf(stderr,
+                        "Warning:  Unable to resize %s:  %s\n",
+                        id3v2apicpicturetype(a.picturetype),
+                        err)
+            continue
+        }
+
+        if resized {
+            i.frames[j] = a.frame()
+            n++
+        }
+    }
+
+    return n
+}
+
+
+func resizeart(stdin *os.File,
+               stdout *os.File,
+               stderr *os.File,
+               verbose bool,
+               filename string,
+               maxsize int,
+               maxbytes int,
+               quality int,
+               dryrun bool) (err error) {
+    rewrite := func(i *id3v2) (err error) {
+        n := resizeapics(stderr, i, maxsize, maxbytes, quality)
+        fmt.Fprintf(stdout, "Resized %d pictures\n", n)
+        return nil
+    }
+
+    return rewritefile(filename,
+                       dryrun,
+                       func(out io.Writer) mp3adorahandler {
+                           return newmp3adoraid3v2rewritehandler(out, rewrite)
+                       })
+}
+
+
+func mainresizeart(stdin *os.File,
+                   stdout *os.File,
+                   stderr *os.File,
+                   verbose bool,
+                   args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("resizeart", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] resizeart [ options ] filename ...")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagmax := flagset.Int("max",
+                           500,
+                           "Maximum picture width and height in pixels")
+    flagmaxbytes := flagset.Int("maxbytes",
+                                100000,
+                                "Maximum picture size in bytes, re-encoding larger pictures, or 0 for no limit")
+    flagquality := flagset.Int("quality",
+                               85,
+                               "Jpeg quality")
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) == 0 {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    for i, filename := range flagset.Args() {
+        if i > 0 {
+            fmt.Fprintln(stdout)
+        }
+        fmt.Fprintf(stdout, "%s:\n", filename)
+
+        if err := resizeart(stdin,
+                            stdout,
+                            stderr,
+                            verbose,
+                            filename,
+                            *flagmax,
+                            *flagmaxbytes,
+                            *flagquality,
+                            *flagn); err != nil {
+            fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+            return exitfailure
+        }
+    }
+
+    return exitsuccess
+}
@@ -9,6 +9,8 @@ import (
     "flag"
     "fmt"
     "os"
+
+    "golang.org/x/text/encoding"
 )
 
 
@@ -16,8 +18,21 @@ func show(stdin *os.File,
           stdout *os.File,
           stderr *os.File,
           verbose bool,
+          encodingname string,
+          detect bool,
           filename string) (size int, err error) {
-    mp3adorashowhandler := newmp3adorashowhandler(stdout, stderr)
+    var e encoding.Encoding
+    if encodingname != "utf-8" {
+        if e, err = find(encodingname); err != nil {
+            return 0, err
+        }
+    }
+
+    mp3adorashowhandler := newmp3adorashowhandler(stdout,
+                                                 stderr,
+                                                 verbose,
+                                                 e,
+                                                 detect)
     mp3adora := newmp3adora(mp3adorashowhandler)
 
     var file *os.File
@@ -35,6 +50,13 @@ func show(stdin *os.File,
         return 0, err
     }
 
+    if mp3adorashowhandler.crcframes > 0 {
+        fmt.Fprintf(stdout,
+                    "crcerrors:  %d of %d protected frames\n",
+                    mp3adorashowhandler.crcerrors,
+                    mp3adorashowhandler.crcframes)
+    }
+
     return size, nil
 }
 
@@ -47,16 +69,32 @@ func mainshow(stdin *os.File,
     flagset := flag.NewFlagSet("show", flag.ExitOnError)
 
     flagset.Usage = func() {
-        fmt.Fprintln(stdout, "Usage:  mp3adora [ -v ] show [ filename ... ]")
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] show [ options ] [ filename ... ]")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
         flagset.PrintDefaults()
     }
 
+    flagencoding := flagset.String("encoding",
+                                   "windows-1252",
+                                   "Id3v1 encoding, or utf-8 to show as is")
+    flagdetect := flagset.Bool("detect",
+                               false,
+                               "Decode id3v1 and ISO-8859-1 id3v2 text using the likeliest encoding")
+
     // Note flagset.Parse() will also handle '-h' and '--help' and will exit
     // with exit status 2.
     flagset.Parse(args)
 
     if len(flagset.Args()) == 0 {
-        size, err := show(stdin, stdout, stderr, verbose, "")
+        size, err := show(stdin,
+                          stdout,
+                          stderr,
+                          verbose,
+                          *flagencoding,
+                          *flagdetect,
+                          "")
         if err != nil {
             fmt.Fprintf(stderr, "mp3adora: %s\n", err)
             return exitfailure
@@ -69,7 +107,13 @@ func mainshow(stdin *os.File,
             }
             fmt.Fprintf(stdout, "%s:\n", filename)
 
-            size, err := show(stdin, stdout, stderr, verbose, filename)
+            size, err := show(stdin,
+                              stdout,
+                              stderr,
+                              verbose,
+                              *flagencoding,
+                              *flagdetect,
+                              filename)
             if err != nil {
                 fmt.Fprintf(stderr, "mp3adora: %s\n", err)
                 return exitfailure
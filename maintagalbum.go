@@ -14,8 +14,10 @@ import (
     "path"
     "regexp"
     "strconv"
+    "strings"
 
     "golang.org/x/text/encoding"
+    "golang.org/x/text/unicode/norm"
 )
 
 
@@ -25,6 +27,16 @@ func tagalbum(stdin *os.File,
               verbose bool,
               directorypath string,
               encodingname string,
+              transliteration bool,
+              normalisationname string,
+              genrename string,
+              covernames []string,
+              covermax int,
+              covermaxbytes int,
+              coverquality int,
+              padding int,
+              keeplyrics3 bool,
+              writeid3v1extended bool,
               dryrun bool) (err error) {
     var e encoding.Encoding
     if encodingname != "utf-8" {
@@ -34,6 +46,28 @@ func tagalbum(stdin *os.File,
         }
     }
 
+    // Normalise text, e.g. NFD filenames from macOS, before converting and
+    // writing it.
+    normalisetext := func(s string) string {
+        return s
+    }
+    if normalisationname != "none" {
+        var f norm.Form
+        if f, err = findnormalisationform(normalisationname); err != nil {
+            return err
+        }
+        normalisetext = f.String
+    }
+
+    // Transliterate what the encoding cannot represent before falling back
+    // to '?'.
+    converttoencoding := func(s string) (string, error) {
+        if transliteration {
+            s = transliterate(e, s)
+        }
+        return convert(e, s, '?')
+    }
+
     _, directoryname := path.Split(directorypath)
 
     regexpdirectory :=
@@ -43,17 +77,21 @@ func tagalbum(stdin *os.File,
         return fmt.Errorf("Unable to parse directory name %s", directoryname)
     }
 
-    artist := resultdirectory[1]
+    artist := normalisetext(resultdirectory[1])
     year := resultdirectory[2]
-    album := resultdirectory[3]
+    album := normalisetext(resultdirectory[3])
+
+    // Note id3v2 tags are written in UTF-8 regardless of encoding.
+    artistutf8 := artist
+    albumutf8 := album
 
     if encodingname != "utf-8" {
-        if artist, err = convert(e, artist, '?'); err != nil {
+        if artist, err = converttoencoding(artist); err != nil {
             return fmt.Errorf("Unable to convert artist to %s",
                               encodingname)
         }
 
-        if album, err = convert(e, album, '?'); err != nil {
+        if album, err = converttoencoding(album); err != nil {
             return fmt.Errorf("Unable to convert album to %s",
                               encodingname)
         }
@@ -64,6 +102,65 @@ func tagalbum(stdin *os.File,
         return err
     }
 
+    if genrename == "" {
+        var metadata map[string]string
+        if metadata, err = readalbummetadata(directorypath); err != nil {
+            return err
+        }
+        genrename = metadata["genre"]
+    }
+    genrename = normalisetext(genrename)
+
+    genre := byte(id3v1genrenone)
+    genretext := genrename
+    if genrename != "" {
+        var ok bool
+        if genre, ok = findgenre(genrename); !ok {
+            fmt.Fprintf(stderr,
+                        "Warning:  %s is not an id3v1 genre.\n",
+                        genrename)
+        }
+
+        if encodingname != "utf-8" {
+            if genretext, err = converttoencoding(genrename); err != nil {
+                return fmt.Errorf("Unable to convert genre to %s",
+                                  encodingname)
+            }
+        }
+    }
+
+    var cover *id3v2apic
+    if len(covernames) > 0 {
+        var covername string
+        if cover, covername, err = findcover(directorypath,
+                                             covernames); err != nil {
+            return err
+        }
+        if cover != nil {
+            fmt.Fprintf(stdout,
+                        "Embedding %s %s\n",
+                        path.Base(covername),
+                        cover.mimetype)
+
+            if covermax > 0 || covermaxbytes > 0 {
+                var resized bool
+                if resized, err = cover.resize(covermax,
+                                               covermaxbytes,
+                                               coverquality); err != nil {
+                    return fmt.Errorf("Unable to resize %s:  %s",
+                                      path.Base(covername),
+                                      err)
+                }
+                if resized {
+                    fmt.Fprintf(stdout,
+                                "Resized %s to %d bytes\n",
+                                path.Base(covername),
+                                len(cover.data))
+                }
+            }
+        }
+    }
+
     for _, fileinfo := range fileinfos {
         if path.Ext(fileinfo.Name()) != ".mp3" {
             fmt.Fprintf(stdout, "Skipping %s\n", fileinfo.Name())
@@ -79,29 +176,144 @@ func tagalbum(stdin *os.File,
         }
 
         track, _ := strconv.Atoi(resultfile[1])
-        title := resultfile[3]
+        title := normalisetext(resultfile[3])
+        titleutf8 := title
 
         if encodingname != "utf-8" {
-            if title, err = convert(e, title, '?'); err != nil {
+            if title, err = converttoencoding(title); err != nil {
                 return fmt.Errorf("Unable to convert title to %s",
                                   encodingname)
             }
         }
 
-        id3v1 := newid3v1fromitems(title,
-                                   artist,
-                                   album,
+        // Note the id3v1 fields end at a character boundary.
+        head := func(s string) string {
+            s, _ = id3v1split(e, s, 30)
+            return s
+        }
+
+        id3v1 := newid3v1fromitems(head(title),
+                                   head(artist),
+                                   head(album),
                                    year,
                                    "",
                                    byte(track),
-                                   255)
+                                   genre)
+
+        // The extended tag holds the remainder of long titles, artists and
+        // albums, and a free text genre, for players that only read id3v1.
+        var id3v1extended *id3v1extended
+        if writeid3v1extended {
+            id3v1extended = newid3v1extendedfromitems(e,
+                                                      title,
+                                                      artist,
+                                                      album,
+                                                      genretext)
+        }
 
-        file, err := os.Open(path.Join(directorypath, fileinfo.Name()))
+        file, err := os.OpenFile(path.Join(directorypath, fileinfo.Name()),
+                                 os.O_RDWR,
+                                 0)
         if err != nil {
             return err
         }
         defer file.Close()
 
+        // Note an id3v2 tag is only written if the file has one, or for an
+        // embedded cover or lyrics.
+        id3v2, size, err := readleadingid3v2(file)
+        writeid3v2 := id3v2 != nil || err != nil || cover != nil
+        if err != nil {
+            fmt.Fprintf(stderr, "Warning:  Replacing id3v2 tag:  %s\n", err)
+            id3v2, size = nil, 0
+        }
+        if id3v2 == nil {
+            id3v2 = newid3v2()
+        }
+        if err = upgradeid3v2(stdout, id3v2); err != nil {
+            return err
+        }
+
+        id3v2.setframe(newid3v2textframe("TIT2", titleutf8))
+        id3v2.setframe(newid3v2textframe("TPE1", artistutf8))
+        id3v2.setframe(newid3v2textframe("TALB", albumutf8))
+        id3v2.setframe(newid3v2textframe("TDRC", year))
+        id3v2.setframe(newid3v2textframe("TRCK", strconv.Itoa(track)))
+        if genrename != "" {
+            id3v2.setframe(newid3v2textframe("TCON", genretcon(genrename)))
+        }
+        if cover != nil {
+            id3v2.setapic(cover)
+        }
+
+        // Lyrics are read from a '.lrc' or '.txt' file with the same name.
+        for _, extension := range []string{ ".lrc", ".txt" } {
+            filenamelyrics :=
+                path.Join(directorypath,
+                          strings.TrimSuffix(fileinfo.Name(), ".mp3") +
+                          extension)
+
+            lyrics, err := ioutil.ReadFile(filenamelyrics)
+            if os.IsNotExist(err) {
+                continue
+            }
+            if err != nil {
+                return err
+            }
+
+            fmt.Fprintf(stdout, "Embedding %s\n", path.Base(filenamelyrics))
+            writeid3v2 = true
+            if err = id3v2.setlyrics(extension == ".lrc",
+                                     normalisetext(string(lyrics))); err != nil {
+                return err
+            }
+            break
+        }
+
+        // Overwrite the tags in place if the new id3v2 tag, if any, fits in
+        // the existing tag and its padding, otherwise rewrite the whole file.
+        // Note dropping a lyrics3 block, or adding or dropping an id3v1
+        // extended tag, needs the whole file rewriting.
+        if (!writeid3v2 || size > 0 && id3v2fitsinplace(id3v2, size)) &&
+           (keeplyrics3 || !haslyrics3(file)) &&
+           writeid3v1extended == hasid3v1extended(file) {
+            if verbose {
+                fmt.Fprintf(stdout, "Updating tags in place\n")
+            }
+            if dryrun {
+                continue
+            }
+
+            if writeid3v2 {
+                if _, err = writeid3v2inplace(file, id3v2, size); err != nil {
+                    return err
+                }
+            }
+
+            if id3v1extended != nil {
+                if err = writeid3v1extendedinplace(file,
+                                                   id3v1extended); err != nil {
+                    return err
+                }
+            }
+
+            if err = writeid3v1inplace(file, id3v1); err != nil {
+                return err
+            }
+
+            continue
+        }
+
+        if verbose {
+            fmt.Fprintf(stdout, "Rewriting file\n")
+        }
+        if dryrun {
+            continue
+        }
+
+        id3v2.footer = false
+        id3v2.padding = padding
+
         filenew, err := os.Create(path.Join(directorypath,
                                             fmt.Sprintf("%s.new",
                                                         fileinfo.Name())))
@@ -110,7 +322,14 @@ func tagalbum(stdin *os.File,
         }
         defer filenew.Close()
 
-        mp3adoramp3framecopyhandler := newmp3adoramp3framecopyhandler(filenew)
+        if writeid3v2 {
+            if _, err = filenew.Write(id3v2.bytes()); err != nil {
+                return err
+            }
+        }
+
+        mp3adoramp3framecopyhandler := newmp3adoramp3framecopyhandler(filenew,
+                                                                     keeplyrics3)
         mp3adora := newmp3adora(mp3adoramp3framecopyhandler)
 
         if _, err = mp3adora.parse(file); err != nil {
@@ -123,6 +342,12 @@ func tagalbum(stdin *os.File,
             }
         }
 
+        if id3v1extended != nil {
+            if _, err = filenew.Write(id3v1extended.bytes()); err != nil {
+                return err
+            }
+        }
+
         if _, err = filenew.Write(id3v1.bytes()); err != nil {
             return err
         }
@@ -136,6 +361,38 @@ func tagalbum(stdin *os.File,
 }
 
 
+// Album metadata is read from 'album.txt' in the directory, with lines of
+// 'key: value' and keys ignoring case, e.g. 'Genre: Rock'.
+func readalbummetadata(directorypath string) (metadata map[string]string,
+                                              err error) {
+    metadata = map[string]string{}
+
+    bytes, err := ioutil.ReadFile(path.Join(directorypath, "album.txt"))
+    if os.IsNotExist(err) {
+        return metadata, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    for _, line := range strings.Split(string(bytes), "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        j := strings.Index(line, ":")
+        if j == -1 {
+            return nil, fmt.Errorf("Unable to parse album metadata %s", line)
+        }
+        metadata[strings.ToLower(strings.TrimSpace(line[0:j]))] =
+            strings.TrimSpace(line[j + 1:])
+    }
+
+    return metadata, nil
+}
+
+
 func maintagalbum(stdin *os.File,
                   stdout *os.File,
                   stderr *os.File,
@@ -154,6 +411,36 @@ func maintagalbum(stdin *os.File,
     flagencoding := flagset.String("encoding",
                                    "utf-8",
                                    "Encoding")
+    flagtransliterate := flagset.Bool("transliterate",
+                                      false,
+                                      "Transliterate characters the encoding cannot represent")
+    flagnormalise := flagset.String("normalise",
+                                    "nfc",
+                                    "Unicode normalisation form for text, nfc, nfd, nfkc, nfkd or none")
+    flaggenre := flagset.String("genre",
+                                "",
+                                "Genre name or number, otherwise from album.txt")
+    flagcover := flagset.String("cover",
+                                "cover,folder,front",
+                                "Cover image names to embed in order of preference, or none")
+    flagcovermax := flagset.Int("covermax",
+                                0,
+                                "Maximum cover width and height in pixels, or 0 for no limit")
+    flagcovermaxbytes := flagset.Int("covermaxbytes",
+                                     0,
+                                     "Maximum cover size in bytes, re-encoding larger covers, or 0 for no limit")
+    flagcoverquality := flagset.Int("coverquality",
+                                    85,
+                                    "Cover jpeg quality when resizing")
+    flagpadding := flagset.Int("padding",
+                               1024,
+                               "Id3v2 padding in bytes when rewriting")
+    flaglyrics3 := flagset.Bool("lyrics3",
+                                false,
+                                "Keep lyrics3 blocks")
+    flagtagplus := flagset.Bool("tagplus",
+                                false,
+                                "Write an id3v1 extended TAG+ tag")
     flagn := flagset.Bool("n",
                           false,
                           "Dry-run")
@@ -167,6 +454,13 @@ func maintagalbum(stdin *os.File,
         return exitfailure
     }
 
+    covernames := []string{}
+    for _, covername := range strings.Split(*flagcover, ",") {
+        if covername != "" && covername != "none" {
+            covernames = append(covernames, covername)
+        }
+    }
+
     for i, directoryname := range flagset.Args() {
         if i > 0 {
             fmt.Fprintln(stdout)
@@ -179,6 +473,16 @@ func maintagalbum(stdin *os.File,
                            verbose,
                            directoryname,
                            *flagencoding,
+                           *flagtransliterate,
+                           *flagnormalise,
+                           *flaggenre,
+                           covernames,
+                           *flagcovermax,
+                           *flagcovermaxbytes,
+                           *flagcoverquality,
+                           *flagpadding,
+                           *flaglyrics3,
+                           *flagtagplus,
                            *flagn); err != nil {
             fmt.Fprintf(stderr, "mp3adora: %s\n", err)
             return exitfailure
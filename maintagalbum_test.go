@@ -0,0 +1,92 @@
+// 'maintagalbum_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io/ioutil"
+    "os"
+    "path"
+    "testing"
+)
+
+
+// Returns an album directory holding a track and cover.jpg, to be removed by
+// the caller.
+func testalbum(t *testing.T) (directorypath string) {
+    directory, err := ioutil.TempDir("", "mp3adora")
+    if err != nil {
+        t.Fatal(err)
+    }
+    directorypath = path.Join(directory, "Artist - 1999 - Album")
+    if err = os.Mkdir(directorypath, 0755); err != nil {
+        t.Fatal(err)
+    }
+
+    track := append(testmp3frame(), testmp3frame()...)
+    for filename, bytes := range map[string][]byte{
+        "01 - Artist - Title.mp3": track,
+        "cover.jpg":               []byte("\xff\xd8\xff\xe0"),
+    } {
+        err = ioutil.WriteFile(path.Join(directorypath, filename), bytes, 0644)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    return directorypath
+}
+
+
+// Returns the leading id3v2 tag of the track tagged with args.
+func testtagalbum(t *testing.T, args ...string) (i *id3v2) {
+    directorypath := testalbum(t)
+    defer os.RemoveAll(path.Dir(directorypath))
+    stdout := testfile(t, nil)
+    defer os.Remove(stdout.Name())
+    defer stdout.Close()
+
+    args = append(args, directorypath)
+    if maintagalbum(nil, stdout, stdout, false, args) != exitsuccess {
+        t.Fatal("Unable to tag album")
+    }
+
+    file, err := os.Open(path.Join(directorypath, "01 - Artist - Title.mp3"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+
+    if i, _, err = readleadingid3v2(file); err != nil {
+        t.Fatal(err)
+    }
+    return i
+}
+
+
+// The cover is embedded by default.
+func Test_tagalbumcover(t *testing.T) {
+    i := testtagalbum(t)
+    if ! (i != nil &&
+          i.frame("TIT2").text() == "Title" &&
+          i.frame("APIC") != nil) {
+        t.Errorf("Test_tagalbumcover:  failed")
+        return
+    }
+
+    a, err := newid3v2apicfromframe(i.frame("APIC"))
+    if ! (err == nil &&
+          a.mimetype == "image/jpeg" &&
+          a.picturetype == id3v2apicfrontcover &&
+          string(a.data) == "\xff\xd8\xff\xe0") {
+        t.Errorf("Test_tagalbumcover:  failed")
+        return
+    }
+
+    if i = testtagalbum(t, "-cover", "none"); i != nil {
+        t.Errorf("Test_tagalbumcover:  failed")
+        return
+    }
+}
@@ -0,0 +1,121 @@
+// 'mainupgradetags.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+)
+
+
+// Upgrade i to id3v2.4, reporting any frames dropped.
+func upgradeid3v2(stdout io.Writer, i *id3v2) (err error) {
+    dropped, err := i.upgrade()
+    for _, id := range dropped {
+        fmt.Fprintf(stdout, "Dropping %s frame, not in id3v2.4\n", id)
+    }
+    return err
+}
+
+
+func upgradetags(stdin *os.File,
+                 stdout *os.File,
+                 stderr *os.File,
+                 verbose bool,
+                 filename string,
+                 crc bool,
+                 compressthreshold int,
+                 dryrun bool) (err error) {
+    rewrite := func(i *id3v2) (err error) {
+        i.compressthreshold = compressthreshold
+
+        if crc {
+            if i.extendedheader == nil {
+                i.extendedheader = new(id3v2extendedheader)
+            }
+            i.extendedheader.crc = true
+        }
+
+        if i.version == 4 {
+            fmt.Fprintf(stdout, "Already id3v2.%d.%d\n", i.version, i.revision)
+            return nil
+        }
+
+        fmt.Fprintf(stdout, "Upgrading id3v2.%d.%d\n", i.version, i.revision)
+        if verbose {
+            for _, f := range i.frames {
+                fmt.Fprintf(stdout, "    %s:  %d bytes\n", f.id, len(f.data))
+            }
+        }
+
+        return upgradeid3v2(stdout, i)
+    }
+
+    return rewritefile(filename,
+                       dryrun,
+                       func(out io.Writer) mp3adorahandler {
+                           return newmp3adoraid3v2rewritehandler(out, rewrite)
+                       })
+}
+
+
+func mainupgradetags(stdin *os.File,
+                     stdout *os.File,
+                     stderr *os.File,
+                     verbose bool,
+                     args []string) (exitstatus int) {
+    flagset := flag.NewFlagSet("upgradetags", flag.ExitOnError)
+
+    flagset.Usage = func() {
+        fmt.Fprintln(stdout,
+                     "Usage:  mp3adora [ -v ] upgradetags [ options ] filename ...")
+        fmt.Fprintln(stdout)
+        fmt.Fprintln(stdout, "Options:")
+        flagset.PrintDefaults()
+    }
+
+    flagcompress := flagset.Int("compress",
+                                0,
+                                "Compress frames larger than this many bytes")
+    flagcrc := flagset.Bool("crc",
+                            false,
+                            "Write crc in extended header")
+    flagn := flagset.Bool("n",
+                          false,
+                          "Dry-run")
+
+    // Note flagset.Parse() will also handle '-h' and '--help' and will exit
+    // with exit status 2.
+    flagset.Parse(args)
+
+    if len(flagset.Args()) == 0 {
+        flagset.Usage()
+        return exitfailure
+    }
+
+    for i, filename := range flagset.Args() {
+        if i > 0 {
+            fmt.Fprintln(stdout)
+        }
+        fmt.Fprintf(stdout, "%s:\n", filename)
+
+        if err := upgradetags(stdin,
+                              stdout,
+                              stderr,
+                              verbose,
+                              filename,
+                              *flagcrc,
+                              *flagcompress,
+                              *flagn); err != nil {
+            fmt.Fprintf(stderr, "mp3adora: %s\n", err)
+            return exitfailure
+        }
+    }
+
+    return exitsuccess
+}
@@ -8,13 +8,13 @@ package main
 import (
     "bufio"
     "encoding/binary"
-    "fmt"
     "io"
 )
 
 
 type mp3adora struct {
     mp3adorahandler mp3adorahandler
+    landmarks []int
 }
 
 
@@ -83,7 +83,26 @@ func (m *mp3adora) parseid3v1(reader io.Reader) (size int, err error) {
 }
 
 
-func (m *mp3adora) parseid3v2(reader io.Reader) (size int, err error) {
+func (m *mp3adora) parseid3v1extended(reader io.Reader) (size int, err error) {
+    var n int
+
+    size = id3v1extendedsize
+
+    bytes := make([]byte, size)
+    if n, err = io.ReadFull(reader, bytes); n != size || err != nil {
+        return 0, err
+    }
+
+    if err = m.mp3adorahandler.processid3v1extended(bytes); err != nil {
+        return size, err
+    }
+
+    return size, nil
+}
+
+
+func (m *mp3adora) parseid3v2(reader io.Reader,
+                              offset int) (size int, err error) {
     var n int
 
     // First ten bytes are:
@@ -101,11 +120,8 @@ func (m *mp3adora) parseid3v2(reader io.Reader) (size int, err error) {
     }
 
     // See:  http://www.ulduzsoft.com/2012/07/parsing-id3v2-tags-in-the-mp3-files/
-    size = int(bytes10[9]) |
-           int(bytes10[8]) << 7 |
-           int(bytes10[7]) << 14 |
-           int(bytes10[6]) << 21
-    size += 10
+    // Note an id3v2.4 footer is consumed along with the tag.
+    size = id3v2tagsize(bytes10)
 
     bytes := make([]byte, size)
     copy(bytes, bytes10)
@@ -113,6 +129,12 @@ func (m *mp3adora) parseid3v2(reader io.Reader) (size int, err error) {
         return 0, err
     }
 
+    // Note a SEEK frame gives the offset of the next tag, which becomes a
+    // landmark, so the tag is parsed there rather than taken as mp3 frame data.
+    if i, err := newid3v2frombytes(bytes); err == nil && i.seek() >= 0 {
+        m.addlandmark(offset + size + i.seek())
+    }
+
     if err = m.mp3adorahandler.processid3v2(bytes); err != nil {
         return size, err
     }
@@ -121,133 +143,44 @@ func (m *mp3adora) parseid3v2(reader io.Reader) (size int, err error) {
 }
 
 
-func (m *mp3adora) parsemp3frame(reader io.Reader) (size int, err error) {
-    var n int
-
-    bytes4 := make([]byte, 4)
-    if n, err = io.ReadFull(reader, bytes4); n != 4 || err != nil {
+// Note if there turns out to be no lyrics3 block the bytes read are
+// unrecognised.
+func (m *mp3adora) parselyrics3(reader *bufio.Reader) (size int, err error) {
+    bytes, ok, err := readlyrics3(reader)
+    if err != nil {
         return 0, err
     }
 
-    header := binary.BigEndian.Uint32(bytes4)
-
-    header >>= 9
-    paddingbit := header & 0x01
-    header >>= 1
-    samplingrate := header & 0x03
-    header >>= 2
-    bitrate := header & 0x0f
-    header >>= 5
-    layer := header & 0x03
-    header >>= 2
-    audioversion := header & 0x03
-    header >>= 2
-    framesync := header & 0x7ff
-    header >>= 11
-
-    if framesync != 0x7ff || header != 0 {
-        return 0, fmt.Errorf("Unable to parse mp3 frame header.")
+    if !ok {
+        for _, b := range bytes {
+            if err = m.mp3adorahandler.processunrecognised(b); err != nil {
+                return len(bytes), err
+            }
+        }
+        return len(bytes), nil
     }
 
-    bitratevalues := [][]int {
-        //  V1,L1   V1,L2    V1,L3    V2,L1    V2, L2 & L3.
-        {
-            0,      0,       0,       0,       0,           // 0000.
-        },
-        {
-            32,     32,      32,      32,      8,           // 0001.
-        },
-        {
-            64,     48,      40,      48,      16,          // 0010.
-        },
-        {
-            96,     56,      48,      56,      24,          // 0011.
-        },
-        {
-            128,    64,      56,      64,      32,          // 0100.
-        },
-        {
-            160,    80,      64,      80,      40,          // 0101.
-        },
-        {
-            192,    96,      80,      96,      48,          // 0110.
-        },
-        {
-            224,    112,     96,      112,     56,          // 0111.
-        },
-        {
-            256,    128,     112,     128,     64,          // 1000.
-        },
-        {
-            288,    160,     128,     144,     80,          // 1001.
-        },
-        {
-            320,    192,     160,     160,     96,          // 1010.
-        },
-        {
-            352,    224,     192,     176,     112,         // 1011.
-        },
-        {
-            384,    256,     224,     192,     128,         // 1100.
-        },
-        {
-            416,    320,     256,     224,     144,         // 1101.
-        },
-        {
-            448,    384,     320,     256,     160,         // 1110.
-        },
-        {
-            -1,     -1,      -1,      -1,      -1,          // 1111.
-        },
+    if err = m.mp3adorahandler.processlyrics3(bytes); err != nil {
+        return len(bytes), err
     }
 
-    samplingratevalues := [][]int {
-        //  MPEG1   MPEG2    MPEG2.5
-        {
-            44100,  22050,   11025,                         // 00.
-        },
-        {
-            48000,  24000,   12000,                         // 01.
-        },
-        {
-            32000,  16000,    8000,                         // 10.
-        },
-        {
-                0,      0,       0,                         // 11.
-        },
-    }
+    return len(bytes), nil
+}
 
-    var bitratecolumn int
-    if audioversion == 0x03 && layer == 0x03 {
-        bitratecolumn = 0
-    } else if audioversion == 0x03 && layer == 0x02 {
-        bitratecolumn = 1
-    } else if audioversion == 0x03 && layer == 0x01 {
-        bitratecolumn = 2
-    } else if audioversion == 0x02 && layer == 0x03 {
-        bitratecolumn = 3
-    } else if (audioversion == 0x02 && layer == 0x02) ||
-              (audioversion == 0x02 && layer == 0x01) {
-        bitratecolumn = 4
-    } else {
-        return 0, fmt.Errorf("Unable to find mp3 bitrate.")
-    }
 
-    var samplingratecolumn int
-    if audioversion == 0x03 {
-        samplingratecolumn = 0
-    } else if audioversion == 0x02 {
-        samplingratecolumn = 1
-    } else if audioversion == 0x00 {
-        samplingratecolumn = 2
-    } else {
-        return 0, fmt.Errorf("Unable to find mp3 sampling rate.")
+func (m *mp3adora) parsemp3frame(reader io.Reader) (size int, err error) {
+    var n int
+
+    bytes4 := make([]byte, 4)
+    if n, err = io.ReadFull(reader, bytes4); n != 4 || err != nil {
+        return 0, err
     }
 
-    size = 144 * bitratevalues[bitrate][bitratecolumn]
-    size *= 1000
-    size /= samplingratevalues[samplingrate][samplingratecolumn]
-    size += int(paddingbit)
+    header, err := newmp3headerfrombytes(bytes4)
+    if err != nil {
+        return 0, err
+    }
+    size = header.size
 
     bytes := make([]byte, size)
     copy(bytes, bytes4)
@@ -263,8 +196,106 @@ func (m *mp3adora) parsemp3frame(reader io.Reader) (size int, err error) {
 }
 
 
+// Landmarks are offsets at which a tag is known to start, either from a
+// SEEK frame or from scanning the tail of the file.  Mp3 frames are not
+// permitted to run over a landmark.
+func (m *mp3adora) addlandmark(offset int) {
+    for _, landmark := range m.landmarks {
+        if landmark == offset {
+            return
+        }
+    }
+    m.landmarks = append(m.landmarks, offset)
+}
+
+
+func (m *mp3adora) nextlandmark(offset int) (landmark int) {
+    landmark = -1
+    for _, l := range m.landmarks {
+        if l > offset && (landmark == -1 || l < landmark) {
+            landmark = l
+        }
+    }
+    return landmark
+}
+
+
+// Scan backwards from the end of the file for tags appended after the audio:
+// id3v1, then id3v1 extended, then lyrics3, then apev2, then an id3v2.4 tag
+// with footer.
+func (m *mp3adora) scantail(readseeker io.ReadSeeker) (err error) {
+    var start, end int64
+    if start, err = readseeker.Seek(0, io.SeekCurrent); err != nil {
+        return err
+    }
+    if end, err = readseeker.Seek(0, io.SeekEnd); err != nil {
+        return err
+    }
+    defer readseeker.Seek(start, io.SeekStart)
+
+    readat := func(offset int64, n int) (bytes []byte) {
+        if offset < start {
+            return nil
+        }
+        bytes = make([]byte, n)
+        if _, err := readseeker.Seek(offset, io.SeekStart); err != nil {
+            return nil
+        }
+        if _, err := io.ReadFull(readseeker, bytes); err != nil {
+            return nil
+        }
+        return bytes
+    }
+
+    position := end
+
+    if bytes := readat(position - 128, 3);
+       bytes != nil && string(bytes) == "TAG" {
+        position -= 128
+        m.addlandmark(int(position - start))
+
+        if bytes := readat(position - id3v1extendedsize, 4);
+           bytes != nil && string(bytes) == "TAG+" {
+            position -= id3v1extendedsize
+            m.addlandmark(int(position - start))
+        }
+    }
+
+    if size := lyrics3tailsize(readat, start, position); size > 0 {
+        position -= size
+        m.addlandmark(int(position - start))
+    }
+
+    if bytes := readat(position - 32, 32);
+       bytes != nil && string(bytes[0:8]) == "APETAGEX" {
+        size := int64(binary.LittleEndian.Uint32(bytes[12:16]))
+        flags := binary.LittleEndian.Uint32(bytes[20:24])
+        if flags & 0x80000000 != 0 {
+            size += 32
+        }
+        position -= size
+        m.addlandmark(int(position - start))
+    }
+
+    if bytes := readat(position - 10, 10);
+       bytes != nil && string(bytes[0:3]) == "3DI" {
+        position -= int64(id3v2tagsize(bytes))
+        m.addlandmark(int(position - start))
+    }
+
+    return nil
+}
+
+
 func (m *mp3adora) parse(reader io.Reader) (size int, err error) {
-    bufferedreader := bufio.NewReader(reader)
+    m.landmarks = nil
+    if readseeker, ok := reader.(io.ReadSeeker); ok {
+        // Note failure here, e.g. seeking on a pipe, is not fatal.
+        m.scantail(readseeker)
+    }
+
+    // Note the buffer is large enough to peek at a whole lyrics3 v1 block.
+    bufferedreader := bufio.NewReaderSize(reader, 11 + lyrics3v1maxsize + 9)
 
     var bytes []byte
     var sizeframe int
@@ -273,8 +304,17 @@ func (m *mp3adora) parse(reader io.Reader) (size int, err error) {
             break
         }
 
+        // Note an id3v1 extended tag is only recognised when followed by
+        // the id3v1 tag, as an id3v1 title may start with '+'.
         if string(bytes) == "TAG" {
-            if sizeframe, err = m.parseid3v1(bufferedreader); err != nil {
+            if bytes, _ = bufferedreader.Peek(id3v1extendedsize + 3);
+               len(bytes) == id3v1extendedsize + 3 && bytes[3] == '+' &&
+               string(bytes[id3v1extendedsize:]) == "TAG" {
+                sizeframe, err = m.parseid3v1extended(bufferedreader)
+            } else {
+                sizeframe, err = m.parseid3v1(bufferedreader)
+            }
+            if err != nil {
                 break
             }
             size += sizeframe
@@ -282,7 +322,8 @@ func (m *mp3adora) parse(reader io.Reader) (size int, err error) {
         }
 
         if string(bytes) == "ID3" {
-            if sizeframe, err = m.parseid3v2(bufferedreader); err != nil {
+            if sizeframe, err = m.parseid3v2(bufferedreader,
+                                             size); err != nil {
                 break
             }
             size += sizeframe
@@ -293,7 +334,8 @@ func (m *mp3adora) parse(reader io.Reader) (size int, err error) {
             break
         }
 
-        if bytes[0] == 0xff && bytes[1] & 0xe0 == 0xe0 {
+        if bytes[0] == 0xff && bytes[1] & 0xe0 == 0xe0 &&
+           m.ismp3frame(size, bytes) {
             if sizeframe, err = m.parsemp3frame(bufferedreader); err != nil {
                 break
             }
@@ -313,6 +355,17 @@ func (m *mp3adora) parse(reader io.Reader) (size int, err error) {
             continue
         }
 
+        if bytes, err = bufferedreader.Peek(11);
+           err == nil && string(bytes) == "LYRICSBEGIN" {
+            if sizeframe, err = m.parselyrics3(bufferedreader); err != nil {
+                break
+            }
+            if sizeframe > 0 {
+                size += sizeframe
+                continue
+            }
+        }
+
         bytes1 := make([]byte, 1)
         if n, err := io.ReadFull(bufferedreader, bytes1); n != 1 || err != nil {
             return 0, err
@@ -329,3 +382,16 @@ func (m *mp3adora) parse(reader io.Reader) (size int, err error) {
 
     return size, nil
 }
+
+
+// Whether bytes4 is a valid mp3 frame header, for a frame at offset which
+// does not run over a landmark.
+func (m *mp3adora) ismp3frame(offset int, bytes4 []byte) bool {
+    header, err := newmp3headerfrombytes(bytes4)
+    if err != nil {
+        return false
+    }
+
+    landmark := m.nextlandmark(offset)
+    return landmark == -1 || offset + header.size <= landmark
+}
@@ -0,0 +1,228 @@
+// 'mp3adora_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "reflect"
+    "testing"
+)
+
+
+// Records what is parsed as e.g. 'mp3frame 417', with runs of unrecognised
+// bytes as one entry.
+type testrecordhandler struct {
+    parsed []string
+    unrecognised int
+}
+
+
+func (h *testrecordhandler) record(name string, size int) (err error) {
+    if h.unrecognised > 0 {
+        h.parsed = append(h.parsed,
+                          fmt.Sprintf("unrecognised %d", h.unrecognised))
+        h.unrecognised = 0
+    }
+    if name != "" {
+        h.parsed = append(h.parsed, fmt.Sprintf("%s %d", name, size))
+    }
+    return nil
+}
+
+
+func (h *testrecordhandler) processape(bytes []byte) (err error) {
+    return h.record("ape", len(bytes))
+}
+
+
+func (h *testrecordhandler) processid3v1(bytes []byte) (err error) {
+    return h.record("id3v1", len(bytes))
+}
+
+
+func (h *testrecordhandler) processid3v1extended(bytes []byte) (err error) {
+    return h.record("id3v1extended", len(bytes))
+}
+
+
+func (h *testrecordhandler) processid3v2(bytes []byte) (err error) {
+    return h.record("id3v2", len(bytes))
+}
+
+
+func (h *testrecordhandler) processlyrics3(bytes []byte) (err error) {
+    return h.record("lyrics3", len(bytes))
+}
+
+
+func (h *testrecordhandler) processmp3frame(bytes []byte) (err error) {
+    return h.record("mp3frame", len(bytes))
+}
+
+
+func (h *testrecordhandler) processunrecognised(byte byte) (err error) {
+    h.unrecognised++
+    return nil
+}
+
+
+// Hides any Seek method, as for a pipe.
+type testreader struct {
+    reader io.Reader
+}
+
+
+func (r *testreader) Read(bytes []byte) (n int, err error) {
+    return r.reader.Read(bytes)
+}
+
+
+func testparse(reader io.Reader) (parsed []string, err error) {
+    h := &testrecordhandler{}
+    if _, err = newmp3adora(h).parse(reader); err != nil {
+        return nil, err
+    }
+    h.record("", 0)
+    return h.parsed, nil
+}
+
+
+// Returns a silent MPEG 1 layer III frame, 128 kbps, 44100 Hz, 417 bytes.
+func testmp3frame() []byte {
+    bytes := make([]byte, 417)
+    copy(bytes, []byte{ 0xff, 0xfb, 0x90, 0x00 })
+    return bytes
+}
+
+
+// Returns an empty ape tag with header and footer.
+func testape() []byte {
+    bytes := make([]byte, 64)
+    for j, flags := range []uint32{ apeflagheader | apeflagisheader,
+                                    apeflagheader } {
+        b := bytes[j * 32:j * 32 + 32]
+        copy(b[0:8], "APETAGEX")
+        binary.LittleEndian.PutUint32(b[8:12], 2000)
+        binary.LittleEndian.PutUint32(b[12:16], 32)
+        binary.LittleEndian.PutUint32(b[20:24], flags)
+    }
+    return bytes
+}
+
+
+func Test_parseid3v2footer(t *testing.T) {
+    i := &id3v2{ version: 4, padding: 0, footer: true }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    tag := i.bytes()
+
+    if ! (id3v2tagsize(tag[0:10]) == len(tag) &&
+          string(tag[len(tag) - 10:len(tag) - 7]) == "3DI") {
+        t.Errorf("Test_parseid3v2footer:  failed")
+        return
+    }
+
+    stream := append(append([]byte{}, tag...), testmp3frame()...)
+    parsed, err := testparse(&testreader{ bytes.NewReader(stream) })
+    if ! (err == nil &&
+          reflect.DeepEqual(parsed,
+                            []string{ fmt.Sprintf("id3v2 %d", len(tag)),
+                                      "mp3frame 417" })) {
+        t.Errorf("Test_parseid3v2footer:  failed, %v", parsed)
+        return
+    }
+}
+
+
+// A truncated mp3 frame before the appended tags would otherwise run over
+// them.
+func Test_scantail(t *testing.T) {
+    i := &id3v2{ version: 4, footer: true }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    tag := i.bytes()
+    ape := testape()
+    id3v1extended := newid3v1extendedfromitems(nil, "", "", "", "").bytes()
+    id3v1 := newid3v1fromitems("Title", "", "", "", "", 0, 0).bytes()
+
+    stream := []byte{}
+    stream = append(stream, testmp3frame()...)
+    stream = append(stream, testmp3frame()[0:100]...)
+    stream = append(stream, tag...)
+    stream = append(stream, ape...)
+    stream = append(stream, id3v1extended...)
+    stream = append(stream, id3v1...)
+
+    m := newmp3adora(&testrecordhandler{})
+    m.scantail(bytes.NewReader(stream))
+    offset := 517
+    landmarks := []int{ len(stream) - len(id3v1),
+                        len(stream) - len(id3v1) - len(id3v1extended),
+                        offset + len(tag),
+                        offset }
+    if ! reflect.DeepEqual(m.landmarks, landmarks) {
+        t.Errorf("Test_scantail:  failed, %v", m.landmarks)
+        return
+    }
+
+    parsed, err := testparse(bytes.NewReader(stream))
+    if ! (err == nil &&
+          reflect.DeepEqual(parsed,
+                            []string{ "mp3frame 417",
+                                      "unrecognised 100",
+                                      fmt.Sprintf("id3v2 %d", len(tag)),
+                                      "ape 64",
+                                      "id3v1extended 227",
+                                      "id3v1 128" })) {
+        t.Errorf("Test_scantail:  failed, %v", parsed)
+        return
+    }
+
+    // Without the tail scan the truncated frame runs over the id3v2 tag.
+    parsed, err = testparse(&testreader{ bytes.NewReader(stream) })
+    if ! (err == nil &&
+          len(parsed) > 1 &&
+          parsed[1] == "mp3frame 417") {
+        t.Errorf("Test_scantail:  failed, %v", parsed)
+        return
+    }
+}
+
+
+// The tag at the offset given by a SEEK frame is parsed, even if a truncated
+// mp3 frame before it would otherwise run over it.
+func Test_parseid3v2seek(t *testing.T) {
+    data := make([]byte, 4)
+    binary.BigEndian.PutUint32(data, 517)
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    i.setframe(&id3v2frame{ id: "SEEK", data: data })
+    tag := i.bytes()
+
+    i1 := &id3v2{ version: 4 }
+    i1.setframe(newid3v2textframe("TPE1", "Artist"))
+    tag1 := i1.bytes()
+
+    stream := []byte{}
+    stream = append(stream, tag...)
+    stream = append(stream, testmp3frame()...)
+    stream = append(stream, testmp3frame()[0:100]...)
+    stream = append(stream, tag1...)
+    stream = append(stream, testmp3frame()...)
+
+    parsed, err := testparse(&testreader{ bytes.NewReader(stream) })
+    if ! (err == nil &&
+          reflect.DeepEqual(parsed,
+                            []string{ fmt.Sprintf("id3v2 %d", len(tag)),
+                                      "mp3frame 417",
+                                      "unrecognised 100",
+                                      fmt.Sprintf("id3v2 %d", len(tag1)),
+                                      "mp3frame 417" })) {
+        t.Errorf("Test_parseid3v2seek:  failed, %v", parsed)
+        return
+    }
+}
@@ -0,0 +1,95 @@
+// 'mp3adoracollecthandler.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+)
+
+
+// Collects the decoded tags.  Tags which fail to decode are ignored.
+type mp3adoracollecthandler struct {
+    apes []*ape
+    id3v1s []*id3v1
+    id3v1extendeds []*id3v1extended
+    id3v2s []*id3v2
+    lyrics3s []*lyrics3
+    mp3header *mp3header
+}
+
+
+func newmp3adoracollecthandler() *mp3adoracollecthandler {
+    return &mp3adoracollecthandler{}
+}
+
+
+func (h *mp3adoracollecthandler) processape(bytes []byte) (err error) {
+    if a, err := newapefrombytes(bytes); err == nil {
+        h.apes = append(h.apes, a)
+    }
+    return nil
+}
+
+
+func (h *mp3adoracollecthandler) processid3v1(bytes []byte) (err error) {
+    if i, err := newid3v1frombytes(bytes); err == nil {
+        h.id3v1s = append(h.id3v1s, i)
+    }
+    return nil
+}
+
+
+func (h *mp3adoracollecthandler) processid3v1extended(bytes []byte) (err error) {
+    if e, err := newid3v1extendedfrombytes(bytes); err == nil {
+        h.id3v1extendeds = append(h.id3v1extendeds, e)
+    }
+    return nil
+}
+
+
+func (h *mp3adoracollecthandler) processid3v2(bytes []byte) (err error) {
+    if i, err := newid3v2frombytes(bytes); err == nil {
+        h.id3v2s = append(h.id3v2s, i)
+    }
+    return nil
+}
+
+
+func (h *mp3adoracollecthandler) processlyrics3(bytes []byte) (err error) {
+    if l, err := newlyrics3frombytes(bytes); err == nil {
+        h.lyrics3s = append(h.lyrics3s, l)
+    }
+    return nil
+}
+
+
+// Note only the first mp3 frame header is kept.
+func (h *mp3adoracollecthandler) processmp3frame(bytes []byte) (err error) {
+    if h.mp3header == nil {
+        h.mp3header, _ = newmp3headerfrombytes(bytes)
+    }
+    return nil
+}
+
+
+func (h *mp3adoracollecthandler) processunrecognised(byte byte) (err error) {
+    return nil
+}
+
+
+// Returns the pictures from id3v2 APIC frames and ape cover art items.
+func (h *mp3adoracollecthandler) pictures() (apics []*id3v2apic) {
+    for _, i := range h.id3v2s {
+        for _, f := range i.frames {
+            if a, err := newid3v2apicfromframe(f); err == nil {
+                apics = append(apics, a)
+            }
+        }
+    }
+    for _, a := range h.apes {
+        apics = append(apics, a.coverart()...)
+    }
+    return apics
+}
@@ -0,0 +1,123 @@
+// 'mp3adoracuthandler.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io"
+)
+
+
+// Copies the mp3 frames from first up to end to out, with the frames before
+// start silenced, as they are only needed for the bit reservoir of the frame
+// at start.  Xing, if any, is written before the first frame.  If copytags
+// then the tags are copied too, with id3v2 tags passed to rewrite, if any.
+type mp3adoracuthandler struct {
+    out io.Writer
+    first int
+    start int
+    end int
+    xing []byte
+    copytags bool
+    rewrite func(i *id3v2) (err error)
+    frame int
+}
+
+
+func newmp3adoracuthandler(out io.Writer,
+                           first int,
+                           start int,
+                           end int,
+                           xing []byte,
+                           copytags bool,
+                           rewrite func(i *id3v2) (err error)) *mp3adoracuthandler {
+    return &mp3adoracuthandler{ out: out,
+                                first: first,
+                                start: start,
+                                end: end,
+                                xing: xing,
+                                copytags: copytags,
+                                rewrite: rewrite }
+}
+
+
+func (h *mp3adoracuthandler) copytag(bytes []byte) (err error) {
+    if !h.copytags {
+        return nil
+    }
+    _, err = h.out.Write(bytes)
+    return err
+}
+
+
+func (h *mp3adoracuthandler) processape(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoracuthandler) processid3v1(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoracuthandler) processid3v1extended(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoracuthandler) processid3v2(bytes []byte) (err error) {
+    if !h.copytags || h.rewrite == nil {
+        return h.copytag(bytes)
+    }
+
+    var i *id3v2
+    if i, err = newid3v2frombytes(bytes); err != nil {
+        return err
+    }
+
+    if err = h.rewrite(i); err != nil {
+        return err
+    }
+
+    return h.copytag(i.bytes())
+}
+
+
+func (h *mp3adoracuthandler) processlyrics3(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoracuthandler) processmp3frame(bytes []byte) (err error) {
+    j := h.frame
+    h.frame++
+    if j < h.first || j >= h.end {
+        return nil
+    }
+
+    if j == h.first && h.xing != nil {
+        if _, err = h.out.Write(h.xing); err != nil {
+            return err
+        }
+    }
+
+    if j < h.start {
+        var m *mp3header
+        if m, err = newmp3headerfrombytes(bytes); err != nil {
+            return err
+        }
+        if err = m.silence(bytes); err != nil {
+            return err
+        }
+    }
+
+    _, err = h.out.Write(bytes)
+    return err
+}
+
+
+func (h *mp3adoracuthandler) processunrecognised(byte byte) (err error) {
+    return nil
+}
@@ -0,0 +1,120 @@
+// 'mp3adoraframeindexhandler.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+)
+
+
+// Offset in bytes and start time in milliseconds of an mp3 frame.
+type mp3frameindex struct {
+    offset int
+    size int
+    time float64
+    mp3header *mp3header
+    xing *xing
+}
+
+
+// Indexes the mp3 frames, keeping track of the offset of everything parsed.
+type mp3adoraframeindexhandler struct {
+    offset int
+    time float64
+    frames []*mp3frameindex
+}
+
+
+func newmp3adoraframeindexhandler() *mp3adoraframeindexhandler {
+    return &mp3adoraframeindexhandler{}
+}
+
+
+func (h *mp3adoraframeindexhandler) processape(bytes []byte) (err error) {
+    h.offset += len(bytes)
+    return nil
+}
+
+
+func (h *mp3adoraframeindexhandler) processid3v1(bytes []byte) (err error) {
+    h.offset += len(bytes)
+    return nil
+}
+
+
+func (h *mp3adoraframeindexhandler) processid3v1extended(bytes []byte) (err error) {
+    h.offset += len(bytes)
+    return nil
+}
+
+
+func (h *mp3adoraframeindexhandler) processid3v2(bytes []byte) (err error) {
+    h.offset += len(bytes)
+    return nil
+}
+
+
+func (h *mp3adoraframeindexhandler) processlyrics3(bytes []byte) (err error) {
+    h.offset += len(bytes)
+    return nil
+}
+
+
+func (h *mp3adoraframeindexhandler) processmp3frame(bytes []byte) (err error) {
+    var m *mp3header
+    if m, err = newmp3headerfrombytes(bytes); err != nil {
+        return err
+    }
+
+    f := &mp3frameindex{ offset: h.offset,
+                         size: len(bytes),
+                         time: h.time,
+                         mp3header: m }
+    h.frames = append(h.frames, f)
+    h.offset += len(bytes)
+
+    // Note a Xing frame before the audio is silent.
+    if len(h.frames) == 1 {
+        if f.xing, err = newxingfromframe(bytes); err == nil {
+            return nil
+        }
+    }
+
+    h.time += m.duration()
+    return nil
+}
+
+
+func (h *mp3adoraframeindexhandler) processunrecognised(byte byte) (err error) {
+    h.offset++
+    return nil
+}
+
+
+// Returns the index of the first frame starting at or after time, or
+// len(h.frames) if there is none.
+func (h *mp3adoraframeindexhandler) frameat(time float64) int {
+    for j, f := range h.frames {
+        if f.time >= time {
+            return j
+        }
+    }
+    return len(h.frames)
+}
+
+
+// Returns the offset just after the last frame.
+func (h *mp3adoraframeindexhandler) end() int {
+    if len(h.frames) == 0 {
+        return 0
+    }
+    f := h.frames[len(h.frames) - 1]
+    return f.offset + f.size
+}
+
+
+func (h *mp3adoraframeindexhandler) duration() float64 {
+    return h.time
+}
@@ -0,0 +1,77 @@
+// 'mp3adoraid3v2rewritehandler.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io"
+)
+
+
+// Copies everything verbatim except id3v2 tags, which are decoded, passed
+// to rewrite and encoded again.
+type mp3adoraid3v2rewritehandler struct {
+    out io.Writer
+    rewrite func(i *id3v2) (err error)
+}
+
+
+func newmp3adoraid3v2rewritehandler(out io.Writer,
+                                    rewrite func(i *id3v2) (err error)) *mp3adoraid3v2rewritehandler {
+    return &mp3adoraid3v2rewritehandler{ out: out,
+                                         rewrite: rewrite }
+}
+
+
+func (h *mp3adoraid3v2rewritehandler) write(bytes []byte) (err error) {
+    if _, err := h.out.Write(bytes); err != nil {
+        return err
+    }
+    return nil
+}
+
+
+func (h *mp3adoraid3v2rewritehandler) processape(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraid3v2rewritehandler) processid3v1(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraid3v2rewritehandler) processid3v1extended(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraid3v2rewritehandler) processid3v2(bytes []byte) (err error) {
+    var i *id3v2
+    if i, err = newid3v2frombytes(bytes); err != nil {
+        return err
+    }
+
+    if err = h.rewrite(i); err != nil {
+        return err
+    }
+
+    return h.write(i.bytes())
+}
+
+
+func (h *mp3adoraid3v2rewritehandler) processlyrics3(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraid3v2rewritehandler) processmp3frame(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraid3v2rewritehandler) processunrecognised(byte byte) (err error) {
+    return h.write([]uint8{ byte })
+}
@@ -6,45 +6,76 @@ package main
 
 
 import (
-    "fmt"
     "io"
 )
 
 
+// Copies everything except the id3v2 tag at the start, which is being
+// replaced, the id3v1 and id3v1 extended tags, and unless keeplyrics3 any
+// lyrics3 blocks.  So the copy keeps what updating the tags in place keeps.
 type mp3adoramp3framecopyhandler struct {
     out io.Writer
+    keeplyrics3 bool
+    offset int
 }
 
 
-func newmp3adoramp3framecopyhandler(out io.Writer) *mp3adoramp3framecopyhandler {
-    return &mp3adoramp3framecopyhandler{ out: out }
+func newmp3adoramp3framecopyhandler(out io.Writer,
+                                    keeplyrics3 bool) *mp3adoramp3framecopyhandler {
+    return &mp3adoramp3framecopyhandler{ out: out,
+                                         keeplyrics3: keeplyrics3 }
 }
 
 
-func (h *mp3adoramp3framecopyhandler) processape(bytes []byte) (err error) {
+func (h *mp3adoramp3framecopyhandler) write(bytes []byte) (err error) {
+    h.offset += len(bytes)
+    if _, err := h.out.Write(bytes); err != nil {
+        return err
+    }
     return nil
 }
 
 
+func (h *mp3adoramp3framecopyhandler) processape(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
 func (h *mp3adoramp3framecopyhandler) processid3v1(bytes []byte) (err error) {
+    h.offset += len(bytes)
     return nil
 }
 
 
-func (h *mp3adoramp3framecopyhandler) processid3v2(bytes []byte) (err error) {
+func (h *mp3adoramp3framecopyhandler) processid3v1extended(bytes []byte) (err error) {
+    h.offset += len(bytes)
     return nil
 }
 
 
-func (h *mp3adoramp3framecopyhandler) processmp3frame(bytes []byte) (err error) {
-    if _, err := h.out.Write(bytes); err != nil {
-        return err
+func (h *mp3adoramp3framecopyhandler) processid3v2(bytes []byte) (err error) {
+    if h.offset == 0 {
+        h.offset += len(bytes)
+        return nil
     }
-    return nil
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoramp3framecopyhandler) processlyrics3(bytes []byte) (err error) {
+    if !h.keeplyrics3 {
+        h.offset += len(bytes)
+        return nil
+    }
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoramp3framecopyhandler) processmp3frame(bytes []byte) (err error) {
+    return h.write(bytes)
 }
 
 
 func (h *mp3adoramp3framecopyhandler) processunrecognised(byte byte) (err error) {
-    fmt.Fprintf(h.out, "Unrecognised %d %c\n", byte, byte)
-    return nil
+    return h.write([]uint8{ byte })
 }
@@ -8,55 +8,357 @@ package main
 import (
     "fmt"
     "io"
+    "strings"
+
+    "golang.org/x/text/encoding"
 )
 
 
 type mp3adorashowhandler struct {
     stdout io.Writer
     stderr io.Writer
+    verbose bool
+    encoding encoding.Encoding
+    detect bool
+    id3v1extended *id3v1extended
+    offset int
+    mp3frames int
+    crcframes int
+    crcerrors int
 }
 
 
+// If verbose then the Layer III side information of each mp3 frame is shown.
+// Id3v1 tags are decoded from encoding, or shown as is if encoding is nil.
+// If detect then id3v1 tags and id3v2 ISO-8859-1 text frames are decoded
+// from the likeliest encoding instead.
 func newmp3adorashowhandler(stdout io.Writer,
-                            stderr io.Writer) *mp3adorashowhandler {
+                            stderr io.Writer,
+                            verbose bool,
+                            encoding encoding.Encoding,
+                            detect bool) *mp3adorashowhandler {
     return &mp3adorashowhandler{ stdout: stdout,
-                                 stderr: stderr }
+                                 stderr: stderr,
+                                 verbose: verbose,
+                                 encoding: encoding,
+                                 detect: detect }
 }
 
 
 func (h *mp3adorashowhandler) processape(bytes []byte) (err error) {
+    h.offset += len(bytes)
+
     fmt.Fprintf(h.stdout, "ape:       %d bytes:  %v\n", len(bytes), bytes)
     return nil
 }
 
 
 func (h *mp3adorashowhandler) processid3v1(bytes []byte) (err error) {
+    h.offset += len(bytes)
+
     var i *id3v1
     if i, err = newid3v1frombytes(bytes); err != nil {
         return err
     }
 
+    // Merge the extended tag which immediately precedes the id3v1 tag.
+    if h.id3v1extended != nil {
+        h.id3v1extended.merge(i, bytes)
+        h.id3v1extended = nil
+    }
+
+    e := h.encoding
+    guesses := i.detectencoding()
+    if h.detect && len(guesses) > 0 {
+        e = guesses[0].encoding
+    }
+
+    if e != nil {
+        if err = i.decode(e); err != nil {
+            return err
+        }
+    }
+
     fmt.Fprintf(h.stdout, "id3v1:     %d bytes:  ", len(bytes))
     fmt.Fprintf(h.stdout, "header: %s, ", i.header)
+    fmt.Fprintf(h.stdout, "version: 1.%d, ", i.revision)
     fmt.Fprintf(h.stdout, "title: %s, ", i.title)
     fmt.Fprintf(h.stdout, "artist: %s, ", i.artist)
     fmt.Fprintf(h.stdout, "album: %s, ", i.album)
     fmt.Fprintf(h.stdout, "year: %s, ", i.year)
     fmt.Fprintf(h.stdout, "comment: %s, ", i.comment)
     fmt.Fprintf(h.stdout, "track: %d, ", i.track)
-    fmt.Fprintf(h.stdout, "genre: %d\n", i.genre)
+    if name := genrename(i.genre); name != "" {
+        fmt.Fprintf(h.stdout, "genre: %d %s", i.genre, name)
+    } else {
+        fmt.Fprintf(h.stdout, "genre: %d", i.genre)
+    }
+    if len(guesses) > 0 {
+        fmt.Fprintf(h.stdout, ", likely encoding: %s", guesses[0].name)
+    }
+    fmt.Fprintln(h.stdout)
+
+    return nil
+}
+
+
+func (h *mp3adorashowhandler) processid3v1extended(bytes []byte) (err error) {
+    h.offset += len(bytes)
+
+    var e *id3v1extended
+    if e, err = newid3v1extendedfrombytes(bytes); err != nil {
+        return err
+    }
+
+    genre := e.genre
+    charset := h.encoding
+    if guesses := e.detectencoding(); h.detect && len(guesses) > 0 {
+        charset = guesses[0].encoding
+    }
+    if charset != nil {
+        if genre, err = decode(charset, genre); err != nil {
+            return err
+        }
+    }
+
+    fmt.Fprintf(h.stdout, "id3v1+:    %d bytes:  ", len(bytes))
+    fmt.Fprintf(h.stdout, "header: %s, ", e.header)
+    fmt.Fprintf(h.stdout, "speed: %s, ", e.speedname())
+    fmt.Fprintf(h.stdout, "genre: %s, ", genre)
+    fmt.Fprintf(h.stdout, "start: %s, ", e.starttime)
+    fmt.Fprintf(h.stdout, "end: %s\n", e.endtime)
+
+    h.id3v1extended = e
 
     return nil
 }
 
 
 func (h *mp3adorashowhandler) processid3v2(bytes []byte) (err error) {
-    fmt.Fprintf(h.stdout, "id3v2:     %d bytes:  %v\n", len(bytes), bytes)
+    h.offset += len(bytes)
+
+    var i *id3v2
+    if i, err = newid3v2frombytes(bytes); err != nil {
+        fmt.Fprintf(h.stderr, "Warning:  %s\n", err)
+        fmt.Fprintf(h.stdout, "id3v2:     %d bytes:  %v\n", len(bytes), bytes)
+        return nil
+    }
+
+    fmt.Fprintf(h.stdout, "id3v2:     %d bytes:  ", len(bytes))
+    fmt.Fprintf(h.stdout, "version: 2.%d.%d, ", i.version, i.revision)
+    fmt.Fprintf(h.stdout, "flags: %d, ", i.flags)
+    fmt.Fprintf(h.stdout, "frames: %d, ", len(i.frames))
+    fmt.Fprintf(h.stdout, "padding: %d, ", i.padding)
+    fmt.Fprintf(h.stdout, "footer: %t\n", i.footer)
+
+    if e := i.extendedheader; e != nil {
+        fmt.Fprintf(h.stdout, "    extended header:  ")
+        if i.version == 3 {
+            fmt.Fprintf(h.stdout, "paddingsize: %d, ", e.paddingsize)
+        } else {
+            fmt.Fprintf(h.stdout, "update: %t, ", e.update)
+        }
+        if e.crc {
+            fmt.Fprintf(h.stdout, "crc: %08x, ", e.crc32)
+            fmt.Fprintf(h.stdout, "crc calculated: %08x, ", e.crc32calculated)
+            fmt.Fprintf(h.stdout, "crc ok: %t", e.crc32 == e.crc32calculated)
+        } else {
+            fmt.Fprintf(h.stdout, "crc: none")
+        }
+        if e.restrictions {
+            fmt.Fprintf(h.stdout,
+                        ", restrictions:  tag size: %s, ",
+                        id3v2restrictionstagsizes[e.restrictionstagsize])
+            fmt.Fprintf(h.stdout,
+                        "text encoding: %s, ",
+                        id3v2restrictionstextencodings[e.restrictionstextencoding])
+            fmt.Fprintf(h.stdout,
+                        "text size: %s, ",
+                        id3v2restrictionstextsizes[e.restrictionstextsize])
+            fmt.Fprintf(h.stdout,
+                        "image encoding: %s, ",
+                        id3v2restrictionsimageencodings[e.restrictionsimageencoding])
+            fmt.Fprintf(h.stdout,
+                        "image size: %s",
+                        id3v2restrictionsimagesizes[e.restrictionsimagesize])
+        }
+        fmt.Fprintln(h.stdout)
+
+        if e.crc && e.crc32 != e.crc32calculated {
+            fmt.Fprintf(h.stderr, "Warning:  id3v2 crc mismatch.\n")
+        }
+    }
+
+    for _, f := range i.frames {
+        switch {
+            case f.id == "TCON":
+                fmt.Fprintf(h.stdout,
+                            "    %s:  %s (%s)\n",
+                            f.id,
+                            f.text(),
+                            strings.Join(tcongenres(f.texts()), ", "))
+            case f.id[0] == 'T':
+                h.showtext(f)
+            case f.id == "USLT":
+                h.showuslt(f)
+            case f.id == "SYLT":
+                h.showsylt(f)
+            case f.id == "CHAP":
+                h.showchap(i.version, f)
+            case f.id == "CTOC":
+                h.showctoc(i.version, f)
+            default:
+                fmt.Fprintf(h.stdout, "    %s:  %d bytes\n", f.id, len(f.data))
+        }
+    }
+
+    return nil
+}
+
+
+// ISO-8859-1 text frames are often in some other encoding, which is reported
+// unless it is ISO-8859-1 or windows-1252.
+func (h *mp3adorashowhandler) showtext(f *id3v2frame) {
+    texts := f.latin1texts()
+    guesses := detectencoding(strings.Join(texts, " "))
+    if len(guesses) == 0 ||
+       guesses[0].name == "iso8859-1" || guesses[0].name == "windows-1252" {
+        fmt.Fprintf(h.stdout, "    %s:  %s\n", f.id, f.text())
+        return
+    }
+
+    if !h.detect {
+        fmt.Fprintf(h.stdout,
+                    "    %s:  %s, likely encoding: %s\n",
+                    f.id,
+                    f.text(),
+                    guesses[0].name)
+        return
+    }
+
+    for j := range texts {
+        texts[j], _ = decode(guesses[0].encoding, texts[j])
+    }
+    fmt.Fprintf(h.stdout,
+                "    %s:  %s, decoded as: %s\n",
+                f.id,
+                strings.Join(texts, "/"),
+                guesses[0].name)
+}
+
+
+func (h *mp3adorashowhandler) showuslt(f *id3v2frame) {
+    u, err := newid3v2usltfromframe(f)
+    if err != nil {
+        fmt.Fprintf(h.stdout, "    %s:  %d bytes\n", f.id, len(f.data))
+        return
+    }
+
+    fmt.Fprintf(h.stdout, "    %s:  ", f.id)
+    fmt.Fprintf(h.stdout, "language: %s, ", u.language)
+    fmt.Fprintf(h.stdout, "description: %s\n", u.description)
+    for _, line := range strings.Split(u.text, "\n") {
+        fmt.Fprintf(h.stdout, "        %s\n", strings.TrimRight(line, "\r"))
+    }
+}
+
+
+func (h *mp3adorashowhandler) showsylt(f *id3v2frame) {
+    s, err := newid3v2syltfromframe(f)
+    if err != nil {
+        fmt.Fprintf(h.stdout, "    %s:  %d bytes\n", f.id, len(f.data))
+        return
+    }
+
+    fmt.Fprintf(h.stdout, "    %s:  ", f.id)
+    fmt.Fprintf(h.stdout, "language: %s, ", s.language)
+    fmt.Fprintf(h.stdout, "timestampformat: %d, ", s.timestampformat)
+    fmt.Fprintf(h.stdout, "contenttype: %d, ", s.contenttype)
+    fmt.Fprintf(h.stdout, "description: %s\n", s.description)
+    for _, line := range s.lines {
+        if s.timestampformat == id3v2sylttimestampmilliseconds {
+            fmt.Fprintf(h.stdout,
+                        "        %s%s\n",
+                        formatlrctimestamp(line.timestamp),
+                        line.text)
+        } else {
+            fmt.Fprintf(h.stdout, "        [%d]%s\n", line.timestamp, line.text)
+        }
+    }
+}
+
+
+func (h *mp3adorashowhandler) showchap(version byte, f *id3v2frame) {
+    c, err := newid3v2chapfromframe(version, f)
+    if err != nil {
+        fmt.Fprintf(h.stdout, "    %s:  %d bytes\n", f.id, len(f.data))
+        return
+    }
+
+    fmt.Fprintf(h.stdout, "    %s:  ", f.id)
+    fmt.Fprintf(h.stdout, "elementid: %s, ", c.elementid)
+    fmt.Fprintf(h.stdout, "start: %s, ", formatchaptertime(c.starttime))
+    fmt.Fprintf(h.stdout, "end: %s, ", formatchaptertime(c.endtime))
+    fmt.Fprintf(h.stdout, "startoffset: %d, ", c.startoffset)
+    fmt.Fprintf(h.stdout, "endoffset: %d, ", c.endoffset)
+    fmt.Fprintf(h.stdout, "title: %s\n", id3v2chaptertitle(c.frames))
+}
+
+
+func (h *mp3adorashowhandler) showctoc(version byte, f *id3v2frame) {
+    c, err := newid3v2ctocfromframe(version, f)
+    if err != nil {
+        fmt.Fprintf(h.stdout, "    %s:  %d bytes\n", f.id, len(f.data))
+        return
+    }
+
+    fmt.Fprintf(h.stdout, "    %s:  ", f.id)
+    fmt.Fprintf(h.stdout, "elementid: %s, ", c.elementid)
+    fmt.Fprintf(h.stdout, "toplevel: %t, ", c.toplevel)
+    fmt.Fprintf(h.stdout, "ordered: %t, ", c.ordered)
+    fmt.Fprintf(h.stdout, "entries: %s\n", strings.Join(c.childelementids, " "))
+}
+
+
+func (h *mp3adorashowhandler) processlyrics3(bytes []byte) (err error) {
+    h.offset += len(bytes)
+
+    var l *lyrics3
+    if l, err = newlyrics3frombytes(bytes); err != nil {
+        fmt.Fprintf(h.stderr, "Warning:  %s\n", err)
+        fmt.Fprintf(h.stdout, "lyrics3:   %d bytes:  %v\n", len(bytes), bytes)
+        return nil
+    }
+
+    fmt.Fprintf(h.stdout, "lyrics3:   %d bytes:  ", len(bytes))
+    fmt.Fprintf(h.stdout, "version: %d\n", l.version)
+
+    if l.version == 1 {
+        for _, line := range strings.Split(l.lyrics, "\n") {
+            fmt.Fprintf(h.stdout, "        %s\n", strings.TrimRight(line, "\r"))
+        }
+        return nil
+    }
+
+    for _, field := range l.fields {
+        if field.id != "LYR" {
+            fmt.Fprintf(h.stdout, "    %s:  %s\n", field.id, field.value)
+            continue
+        }
+        fmt.Fprintf(h.stdout, "    %s:\n", field.id)
+        for _, line := range strings.Split(field.value, "\n") {
+            fmt.Fprintf(h.stdout, "        %s\n", strings.TrimRight(line, "\r"))
+        }
+    }
+
     return nil
 }
 
 
 func (h *mp3adorashowhandler) processmp3frame(bytes []byte) (err error) {
+    offset := h.offset
+    h.offset += len(bytes)
+
     var m *mp3header
     if m, err = newmp3headerfrombytes(bytes); err != nil {
         return err
@@ -76,11 +378,112 @@ func (h *mp3adorashowhandler) processmp3frame(bytes []byte) (err error) {
     fmt.Fprintf(h.stdout, "original: %t, ", m.original)
     fmt.Fprintf(h.stdout, "emphasis: %d\n", m.emphasis)
 
+    if m.protection {
+        h.showcrc(offset, m, bytes)
+    }
+
+    // Note a Xing header can only be in the first mp3 frame.
+    h.mp3frames++
+    if h.mp3frames == 1 {
+        if x, err := newxingfromframe(bytes); err == nil {
+            h.showxing(x)
+        }
+    }
+
+    if h.verbose && m.sideinfo != nil {
+        h.showsideinfo(m)
+    }
+
     return nil
 }
 
 
+// Shows CRC errors, and all CRCs if verbose.  Frames which cannot be checked
+// are not counted.
+func (h *mp3adorashowhandler) showcrc(offset int,
+                                      m *mp3header,
+                                      bytes []byte) {
+    ok, crc, err := m.verifycrc(bytes)
+    if err != nil {
+        if h.verbose {
+            fmt.Fprintf(h.stdout, "    crc:  0x%04x, unchecked\n", m.crc)
+        }
+        return
+    }
+
+    h.crcframes++
+    if !ok {
+        h.crcerrors++
+        fmt.Fprintf(h.stdout,
+                    "    crc:  0x%04x, computed 0x%04x, error at offset %d\n",
+                    m.crc,
+                    crc,
+                    offset)
+    } else if h.verbose {
+        fmt.Fprintf(h.stdout, "    crc:  0x%04x, ok\n", m.crc)
+    }
+}
+
+
+func (h *mp3adorashowhandler) showxing(x *xing) {
+    fmt.Fprintf(h.stdout, "    xing:  ")
+    fmt.Fprintf(h.stdout, "header: %s, ", x.name)
+    fmt.Fprintf(h.stdout, "flags: %d, ", x.flags)
+    fmt.Fprintf(h.stdout, "frames: %d, ", x.frames)
+    fmt.Fprintf(h.stdout, "bytes: %d, ", x.bytes)
+    fmt.Fprintf(h.stdout, "quality: %d", x.quality)
+    if x.lame != nil {
+        fmt.Fprintf(h.stdout, ", lame: %s", strings.TrimRight(string(x.lame[0:9]), "\x00"))
+    }
+    fmt.Fprintf(h.stdout, "\n")
+}
+
+
+func (h *mp3adorashowhandler) showsideinfo(m *mp3header) {
+    s := m.sideinfo
+
+    fmt.Fprintf(h.stdout, "    sideinfo:  %d bytes:  ", m.sideinfosize())
+    fmt.Fprintf(h.stdout, "maindatabegin: %d, ", s.maindatabegin)
+    fmt.Fprintf(h.stdout, "maindatasize: %d, ", s.maindatasize())
+    fmt.Fprintf(h.stdout, "privatebits: %d", s.privatebits)
+    for ch, scfsi := range s.scfsi {
+        fmt.Fprintf(h.stdout, ", scfsi%d: ", ch)
+        for _, band := range scfsi {
+            if band {
+                fmt.Fprintf(h.stdout, "1")
+            } else {
+                fmt.Fprintf(h.stdout, "0")
+            }
+        }
+    }
+    fmt.Fprintf(h.stdout, "\n")
+
+    for gr, granule := range s.granules {
+        for ch, g := range granule {
+            fmt.Fprintf(h.stdout, "    granule %d channel %d:  ", gr, ch)
+            fmt.Fprintf(h.stdout, "part23length: %d, ", g.part23length)
+            fmt.Fprintf(h.stdout, "bigvalues: %d, ", g.bigvalues)
+            fmt.Fprintf(h.stdout, "globalgain: %d, ", g.globalgain)
+            fmt.Fprintf(h.stdout, "scalefaccompress: %d, ", g.scalefaccompress)
+            fmt.Fprintf(h.stdout, "blocktype: %s, ", g.blocktypename())
+            if g.windowswitching {
+                fmt.Fprintf(h.stdout, "mixedblock: %t, ", g.mixedblock)
+                fmt.Fprintf(h.stdout, "subblockgain: %v, ", g.subblockgain)
+            }
+            fmt.Fprintf(h.stdout, "tableselect: %v, ", g.tableselect)
+            fmt.Fprintf(h.stdout, "region0count: %d, ", g.region0count)
+            fmt.Fprintf(h.stdout, "region1count: %d, ", g.region1count)
+            fmt.Fprintf(h.stdout, "preflag: %t, ", g.preflag)
+            fmt.Fprintf(h.stdout, "scalefacscale: %t, ", g.scalefacscale)
+            fmt.Fprintf(h.stdout, "count1tableselect: %d\n", g.count1tableselect)
+        }
+    }
+}
+
+
 func (h *mp3adorashowhandler) processunrecognised(byte byte) (err error) {
+    h.offset++
+
     fmt.Fprintf(h.stdout, "unrecognised:  1 byte:  %v\n", byte)
     return nil
 }
@@ -0,0 +1,71 @@
+// 'mp3adoratagcopyhandler.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io"
+)
+
+
+// Copies either the leading tags, those before the first mp3 frame, or if
+// trailing the trailing tags, those after it, to out.
+type mp3adoratagcopyhandler struct {
+    out io.Writer
+    trailing bool
+    mp3frames bool
+}
+
+
+func newmp3adoratagcopyhandler(out io.Writer,
+                               trailing bool) *mp3adoratagcopyhandler {
+    return &mp3adoratagcopyhandler{ out: out,
+                                    trailing: trailing }
+}
+
+
+func (h *mp3adoratagcopyhandler) copytag(bytes []byte) (err error) {
+    if h.mp3frames != h.trailing {
+        return nil
+    }
+    _, err = h.out.Write(bytes)
+    return err
+}
+
+
+func (h *mp3adoratagcopyhandler) processape(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoratagcopyhandler) processid3v1(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoratagcopyhandler) processid3v1extended(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoratagcopyhandler) processid3v2(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoratagcopyhandler) processlyrics3(bytes []byte) (err error) {
+    return h.copytag(bytes)
+}
+
+
+func (h *mp3adoratagcopyhandler) processmp3frame(bytes []byte) (err error) {
+    h.mp3frames = true
+    return nil
+}
+
+
+func (h *mp3adoratagcopyhandler) processunrecognised(byte byte) (err error) {
+    return nil
+}
@@ -0,0 +1,83 @@
+// 'mp3adoraxingrewritehandler.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "io"
+)
+
+
+// Copies everything verbatim except the first mp3 frame, which if replace is
+// a Xing frame replaced by xing, otherwise xing is written before it.
+type mp3adoraxingrewritehandler struct {
+    out io.Writer
+    xing []byte
+    replace bool
+    mp3frames bool
+}
+
+
+func newmp3adoraxingrewritehandler(out io.Writer,
+                                   xing []byte,
+                                   replace bool) *mp3adoraxingrewritehandler {
+    return &mp3adoraxingrewritehandler{ out: out,
+                                        xing: xing,
+                                        replace: replace }
+}
+
+
+func (h *mp3adoraxingrewritehandler) write(bytes []byte) (err error) {
+    if _, err := h.out.Write(bytes); err != nil {
+        return err
+    }
+    return nil
+}
+
+
+func (h *mp3adoraxingrewritehandler) processape(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraxingrewritehandler) processid3v1(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraxingrewritehandler) processid3v1extended(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraxingrewritehandler) processid3v2(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraxingrewritehandler) processlyrics3(bytes []byte) (err error) {
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraxingrewritehandler) processmp3frame(bytes []byte) (err error) {
+    if h.mp3frames {
+        return h.write(bytes)
+    }
+    h.mp3frames = true
+
+    if err = h.write(h.xing); err != nil {
+        return err
+    }
+    if h.replace {
+        return nil
+    }
+    return h.write(bytes)
+}
+
+
+func (h *mp3adoraxingrewritehandler) processunrecognised(byte byte) (err error) {
+    return h.write([]uint8{ byte })
+}
@@ -0,0 +1,57 @@
+// 'mp3crc.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "fmt"
+)
+
+
+// See:  ISO/IEC 11172-3 2.4.3.1.
+// CRC-16 with polynomial 0x8005 and initial value 0xffff, most significant
+// bit first, of the bytes given in order.
+func mp3crc16(byteses ...[]byte) uint16 {
+    crc := uint16(0xffff)
+    for _, bytes := range byteses {
+        for _, b := range bytes {
+            crc ^= uint16(b) << 8
+            for j := 0; j < 8; j++ {
+                if crc & 0x8000 != 0 {
+                    crc = crc << 1 ^ 0x8005
+                } else {
+                    crc <<= 1
+                }
+            }
+        }
+    }
+    return crc
+}
+
+
+// Returns the CRC of the frame, computed over the last 2 bytes of the header
+// and the side information.  Only layer III is supported, as for layer II
+// the protected bits depend on the bit allocation tables.
+func (m *mp3header) computecrc(bytes []byte) (crc uint16, err error) {
+    if m.layer != 3 {
+        return 0, fmt.Errorf("Unable to compute crc, not layer III.")
+    }
+    if !m.protection || len(bytes) < m.maindataoffset() {
+        return 0, fmt.Errorf("Unable to compute crc.")
+    }
+
+    return mp3crc16(bytes[2:4],
+                    bytes[m.sideinfooffset():m.maindataoffset()]), nil
+}
+
+
+// Returns whether the CRC of a protected frame matches, and the CRC
+// computed.
+func (m *mp3header) verifycrc(bytes []byte) (ok bool, crc uint16, err error) {
+    if crc, err = m.computecrc(bytes); err != nil {
+        return false, 0, err
+    }
+    return crc == m.crc, crc, nil
+}
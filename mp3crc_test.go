@@ -0,0 +1,99 @@
+// 'mp3crc_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "fmt"
+    "io/ioutil"
+    "os"
+    "strings"
+    "testing"
+)
+
+
+func Test_mp3crc16(t *testing.T) {
+    if ! (mp3crc16([]byte("1234"), []byte("56789")) == 0xaee7) {
+        t.Errorf("Test_mp3crc16:  failed")
+    }
+}
+
+
+func Test_mp3verifycrc(t *testing.T) {
+    frame := make([]byte, 417)
+    copy(frame, []byte{ 0xff, 0xfa, 0x90, 0x00 })
+    copy(frame[6:], mp3sideinfobits("100101100 000 1010 0000"))
+
+    crc := mp3crc16(frame[2:4], frame[6:38])
+    frame[4] = byte(crc >> 8)
+    frame[5] = byte(crc)
+
+    m, err := newmp3headerfrombytes(frame)
+    if err != nil {
+        t.Errorf("Test_mp3verifycrc:  failed")
+        return
+    }
+    if ok, _, err := m.verifycrc(frame); ! (ok && err == nil) {
+        t.Errorf("Test_mp3verifycrc:  failed")
+        return
+    }
+
+    // Corrupting the main data does not matter, but the side info does.
+    frame[100] ^= 0xff
+    if ok, _, err := m.verifycrc(frame); ! (ok && err == nil) {
+        t.Errorf("Test_mp3verifycrc:  failed")
+        return
+    }
+    frame[10] ^= 0x01
+    if ok, crc1, err := m.verifycrc(frame); ! (!ok && crc1 != crc && err == nil) {
+        t.Errorf("Test_mp3verifycrc:  failed")
+    }
+}
+
+
+// Show reports the offset of each frame whose CRC does not match, and a
+// summary of the errors.
+func Test_showcrc(t *testing.T) {
+    i := &id3v2{ version: 4 }
+    i.setframe(newid3v2textframe("TIT2", "Title"))
+    tag := i.bytes()
+
+    frame := make([]byte, 417)
+    copy(frame, []byte{ 0xff, 0xfa, 0x90, 0x00 })
+    copy(frame[6:], mp3sideinfobits("100101100 000 1010 0000"))
+    crc := mp3crc16(frame[2:4], frame[6:38])
+    frame[4] = byte(crc >> 8)
+    frame[5] = byte(crc)
+    frame1 := append([]byte{}, frame...)
+    frame1[10] ^= 0x01
+
+    stream := []byte{}
+    stream = append(stream, tag...)
+    stream = append(stream, frame...)
+    stream = append(stream, frame1...)
+    stream = append(stream, frame...)
+
+    file := testfile(t, stream)
+    defer os.Remove(file.Name())
+    file.Close()
+    stdout := testfile(t, nil)
+    defer os.Remove(stdout.Name())
+    defer stdout.Close()
+    stderr := testfile(t, nil)
+    defer os.Remove(stderr.Name())
+    defer stderr.Close()
+
+    _, err := show(nil, stdout, stderr, false, "utf-8", false, file.Name())
+    bytes, _ := ioutil.ReadFile(stdout.Name())
+    s := string(bytes)
+    if ! (err == nil &&
+          strings.Count(s, "error at offset") == 1 &&
+          strings.Contains(s, fmt.Sprintf("error at offset %d\n",
+                                          len(tag) + 417)) &&
+          strings.HasSuffix(s, "crcerrors:  1 of 3 protected frames\n")) {
+        t.Errorf("Test_showcrc:  failed, %s", s)
+        return
+    }
+}
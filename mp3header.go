@@ -25,9 +25,13 @@ type mp3header struct {
     original bool
     emphasis int
     size int
+    crc uint16
+    sideinfo *mp3sideinfo
 }
 
 
+// Bytes may be just the 4 byte header, or the whole frame, in which case the
+// CRC, if protected, and the Layer III side information are also decoded.
 func newmp3headerfrombytes(bytes []byte) (m *mp3header, err error) {
 
     // Sign:  Length:  Position:  Description:
@@ -35,7 +39,7 @@ func newmp3headerfrombytes(bytes []byte) (m *mp3header, err error) {
     // A      11       (31-21)    Frame sync - all bits must be set.
     // B      2        (20,19)    MPEG Audio version ID.
     // C      2        (18,17)    Layer description.
-    // D      1        (16)       Protection bit.
+    // D      1        (16)       Protection bit - 0 if a CRC follows.
     // E      4        (15,12)    Bitrate index.
     // F      2        (11,10)    Sampling rate frequency index.
     // G      1        (9)        Padding bit.
@@ -186,10 +190,11 @@ func newmp3headerfrombytes(bytes []byte) (m *mp3header, err error) {
         bitratecolumn = 1
     } else if audioversion == 0x03 && layer == 0x01 {
         bitratecolumn = 2
-    } else if audioversion == 0x02 && layer == 0x03 {
+    } else if (audioversion == 0x02 || audioversion == 0x00) &&
+              layer == 0x03 {
         bitratecolumn = 3
-    } else if (audioversion == 0x02 && layer == 0x02) ||
-              (audioversion == 0x02 && layer == 0x01) {
+    } else if (audioversion == 0x02 || audioversion == 0x00) &&
+              (layer == 0x02 || layer == 0x01) {
         bitratecolumn = 4
     } else {
         return nil, fmt.Errorf("Unable to find mp3 bitrate.")
@@ -208,7 +213,7 @@ func newmp3headerfrombytes(bytes []byte) (m *mp3header, err error) {
 
     m.audioversion = audioversionvalues[audioversion]
     m.layer = layervalues[layer]
-    m.protection = protection == 0x01
+    m.protection = protection == 0x00
     m.bitrate = bitratevalues[bitrate][bitratecolumn]
     m.samplingrate = samplingratevalues[samplingrate][samplingratecolumn]
     m.padding = paddingbit == 0x01
@@ -218,10 +223,52 @@ func newmp3headerfrombytes(bytes []byte) (m *mp3header, err error) {
     m.copyright = copyright == 0x01
     m.original = original == 0x01
     m.emphasis = int(emphasis)
-    m.size = 144 * bitratevalues[bitrate][bitratecolumn]
-    m.size *= 1000
-    m.size /= samplingratevalues[samplingrate][samplingratecolumn]
-    m.size += int(paddingbit)
+
+    // Note free format, bitrate 0, is not supported.
+    if m.bitrate <= 0 {
+        return nil, fmt.Errorf("Unable to find mp3 bitrate.")
+    }
+    if m.samplingrate == 0 {
+        return nil, fmt.Errorf("Unable to find mp3 sampling rate.")
+    }
+
+    // Layer I frames are in four byte slots.  Otherwise the size is the
+    // samples per frame, 1152, or 576 for MPEG 2 and 2.5 layer III, each
+    // taking bitrate / samplingrate bits.
+    if m.layer == 1 {
+        m.size = (12 * m.bitrate * 1000 / m.samplingrate + int(paddingbit)) * 4
+    } else {
+        m.size = m.samplesperframe() / 8 * m.bitrate * 1000 / m.samplingrate +
+                 int(paddingbit)
+    }
+
+    if m.protection && len(bytes) >= 6 {
+        m.crc = binary.BigEndian.Uint16(bytes[4:6])
+    }
+
+    if m.layer == 3 && len(bytes) >= m.maindataoffset() {
+        if m.sideinfo, err = newmp3sideinfofrombytes(m, bytes); err != nil {
+            return nil, err
+        }
+    }
 
     return m, nil
 }
+
+
+// See:  http://www.mp3-tech.org/programmer/frame_header.html
+func (m *mp3header) samplesperframe() int {
+    switch {
+        case m.layer == 1:
+            return 384
+        case m.layer == 2 || m.audioversion == 1:
+            return 1152
+    }
+    return 576
+}
+
+
+// Returns the duration of the frame in milliseconds.
+func (m *mp3header) duration() float64 {
+    return float64(m.samplesperframe()) * 1000 / float64(m.samplingrate)
+}
@@ -0,0 +1,63 @@
+// 'mp3header_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+)
+
+
+func Test_mp3headersize(t *testing.T) {
+    for _, test := range []struct {
+        header []byte
+        size int
+    }{
+        // MPEG1 layer I, 384kbps, 44100Hz.
+        { []byte{ 0xff, 0xff, 0xc0, 0x00 }, 416 },
+        // MPEG1 layer I, 384kbps, 44100Hz, padded.
+        { []byte{ 0xff, 0xff, 0xc2, 0x00 }, 420 },
+        // MPEG1 layer II, 256kbps, 44100Hz.
+        { []byte{ 0xff, 0xfd, 0xc0, 0x00 }, 835 },
+        // MPEG1 layer III, 128kbps, 44100Hz, padded.
+        { []byte{ 0xff, 0xfb, 0x92, 0x00 }, 418 },
+        // MPEG2 layer III, 64kbps, 22050Hz.
+        { []byte{ 0xff, 0xf3, 0x80, 0x00 }, 208 },
+        // MPEG2.5 layer III, 64kbps, 11025Hz.
+        { []byte{ 0xff, 0xe3, 0x80, 0x00 }, 417 },
+    } {
+        m, err := newmp3headerfrombytes(test.header)
+        if ! (err == nil && m.size == test.size) {
+            t.Errorf("Test_mp3headersize:  failed, % x", test.header)
+            return
+        }
+    }
+
+    // Free format and reserved sampling rate.
+    for _, header := range [][]byte{ []byte{ 0xff, 0xfb, 0x00, 0x00 },
+                                     []byte{ 0xff, 0xfb, 0x9c, 0x00 } } {
+        if _, err := newmp3headerfrombytes(header); err == nil {
+            t.Errorf("Test_mp3headersize:  failed, % x", header)
+            return
+        }
+    }
+}
+
+
+func Test_parsemp3framempeg2(t *testing.T) {
+    frame := make([]byte, 208)
+    copy(frame, []byte{ 0xff, 0xf3, 0x80, 0x00 })
+    stream := append(append([]byte{}, frame...), frame...)
+
+    parsed, err := testparse(bytes.NewReader(stream))
+    if ! (err == nil &&
+          reflect.DeepEqual(parsed, []string{ "mp3frame 208",
+                                              "mp3frame 208" })) {
+        t.Errorf("Test_parsemp3framempeg2:  failed, %v", parsed)
+        return
+    }
+}
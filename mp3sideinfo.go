@@ -0,0 +1,366 @@
+// 'mp3sideinfo.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "encoding/binary"
+    "fmt"
+)
+
+
+// See:  http://www.mp3-tech.org/programmer/sideinfo.html
+// Layer III side information follows the header and the optional CRC, and
+// describes how to decode the main data, which may begin in earlier frames,
+// the bit reservoir.
+type mp3sideinfo struct {
+    maindatabegin int
+    privatebits int
+    scfsi [][]bool
+    granules [][]*mp3granule
+}
+
+
+// Side information for one granule of one channel.
+type mp3granule struct {
+    part23length int
+    bigvalues int
+    globalgain int
+    scalefaccompress int
+    windowswitching bool
+    blocktype int
+    mixedblock bool
+    tableselect []int
+    subblockgain []int
+    region0count int
+    region1count int
+    preflag bool
+    scalefacscale bool
+    count1tableselect int
+}
+
+
+var mp3granuleblocktypes = []string {
+    "normal",                                           // 0.
+    "start",                                            // 1.
+    "short",                                            // 2.
+    "end",                                              // 3.
+}
+
+
+type mp3bitreader struct {
+    bytes []byte
+    position int
+}
+
+
+// Returns the next n bits, most significant first.
+func (r *mp3bitreader) read(n int) int {
+    value := 0
+    for j := 0; j < n; j++ {
+        bit := r.bytes[r.position / 8] >> (7 - uint(r.position % 8)) & 0x01
+        value = value << 1 | int(bit)
+        r.position++
+    }
+    return value
+}
+
+
+func (r *mp3bitreader) readbool() bool {
+    return r.read(1) == 1
+}
+
+
+type mp3bitwriter struct {
+    bytes []byte
+    position int
+}
+
+
+// Writes the n least significant bits of value, most significant first.
+func (w *mp3bitwriter) write(n int, value int) {
+    for j := n - 1; j >= 0; j-- {
+        if value >> uint(j) & 0x01 == 1 {
+            w.bytes[w.position / 8] |= 0x80 >> uint(w.position % 8)
+        }
+        w.position++
+    }
+}
+
+
+func (w *mp3bitwriter) writebool(value bool) {
+    if value {
+        w.write(1, 1)
+    } else {
+        w.write(1, 0)
+    }
+}
+
+
+// Returns the number of channels, 1 for mono, otherwise 2.
+func (m *mp3header) channels() int {
+    if m.channelmode == 0x03 {
+        return 1
+    }
+    return 2
+}
+
+
+// Returns the number of granules per frame, 2 for MPEG1, otherwise 1.
+func (m *mp3header) granules() int {
+    if m.audioversion == 1 {
+        return 2
+    }
+    return 1
+}
+
+
+// Returns the size of the Layer III side information.
+func (m *mp3header) sideinfosize() int {
+    switch {
+        case m.audioversion == 1 && m.channels() == 1:
+            return 17
+        case m.audioversion == 1:
+            return 32
+        case m.channels() == 1:
+            return 9
+    }
+    return 17
+}
+
+
+// Returns the offset of the side information, after the header and the
+// optional CRC.
+func (m *mp3header) sideinfooffset() int {
+    if m.protection {
+        return 6
+    }
+    return 4
+}
+
+
+// Returns the offset of the main data in the frame, after the side
+// information.
+func (m *mp3header) maindataoffset() int {
+    return m.sideinfooffset() + m.sideinfosize()
+}
+
+
+// Bits are, for MPEG1:
+// main_data_begin:  9.
+// private_bits:     5 for mono, 3 for stereo.
+// scfsi:            4 per channel.
+// Then for each granule and channel:
+// part2_3_length:   12.
+// big_values:       9.
+// global_gain:      8.
+// scalefac_compress:4.
+// window_switching: 1.
+// If window switching:
+//     block_type:   2.
+//     mixed_block:  1.
+//     table_select: 5 x 2.
+//     subblock_gain:3 x 3.
+// Else:
+//     table_select: 5 x 3.
+//     region0_count:4.
+//     region1_count:3.
+// preflag:          1.
+// scalefac_scale:   1.
+// count1table_sel:  1.
+// MPEG2 and MPEG2.5 have a single granule, an 8 bit main_data_begin, 1 or 2
+// private bits, no scfsi, a 9 bit scalefac_compress and no preflag.
+func newmp3sideinfofrombytes(m *mp3header,
+                             bytes []byte) (s *mp3sideinfo, err error) {
+    if m.layer != 3 {
+        return nil, fmt.Errorf("Unable to find side information, not layer III.")
+    }
+    if len(bytes) < m.maindataoffset() {
+        return nil, fmt.Errorf("Unable to find side information.")
+    }
+
+    mpeg1 := m.audioversion == 1
+    channels := m.channels()
+
+    r := &mp3bitreader{ bytes: bytes[m.sideinfooffset():m.maindataoffset()] }
+    s = new(mp3sideinfo)
+
+    if mpeg1 {
+        s.maindatabegin = r.read(9)
+        if channels == 1 {
+            s.privatebits = r.read(5)
+        } else {
+            s.privatebits = r.read(3)
+        }
+
+        s.scfsi = make([][]bool, channels)
+        for ch := 0; ch < channels; ch++ {
+            s.scfsi[ch] = make([]bool, 4)
+            for band := 0; band < 4; band++ {
+                s.scfsi[ch][band] = r.readbool()
+            }
+        }
+    } else {
+        s.maindatabegin = r.read(8)
+        s.privatebits = r.read(channels)
+    }
+
+    s.granules = make([][]*mp3granule, m.granules())
+    for gr := range s.granules {
+        s.granules[gr] = make([]*mp3granule, channels)
+        for ch := 0; ch < channels; ch++ {
+            g := new(mp3granule)
+            g.part23length = r.read(12)
+            g.bigvalues = r.read(9)
+            g.globalgain = r.read(8)
+            if mpeg1 {
+                g.scalefaccompress = r.read(4)
+            } else {
+                g.scalefaccompress = r.read(9)
+            }
+            g.windowswitching = r.readbool()
+
+            if g.windowswitching {
+                g.blocktype = r.read(2)
+                g.mixedblock = r.readbool()
+                g.tableselect = []int{ r.read(5), r.read(5) }
+                g.subblockgain = []int{ r.read(3), r.read(3), r.read(3) }
+                // Note region0_count is implicit for window switching, and
+                // region 1 is the rest of big_values.
+                if g.blocktype == 2 && !g.mixedblock {
+                    g.region0count = 8
+                } else {
+                    g.region0count = 7
+                }
+            } else {
+                g.tableselect = []int{ r.read(5), r.read(5), r.read(5) }
+                g.region0count = r.read(4)
+                g.region1count = r.read(3)
+            }
+
+            if mpeg1 {
+                g.preflag = r.readbool()
+            }
+            g.scalefacscale = r.readbool()
+            g.count1tableselect = r.read(1)
+
+            s.granules[gr][ch] = g
+        }
+    }
+
+    return s, nil
+}
+
+
+func (g *mp3granule) blocktypename() string {
+    return mp3granuleblocktypes[g.blocktype]
+}
+
+
+// Returns the size of the main data in bytes, rounded up.
+func (s *mp3sideinfo) maindatasize() int {
+    bits := 0
+    for _, granule := range s.granules {
+        for _, g := range granule {
+            bits += g.part23length
+        }
+    }
+    return (bits + 7) / 8
+}
+
+
+// Returns the side information encoded as in newmp3sideinfofrombytes().
+func (s *mp3sideinfo) bytes(m *mp3header) []byte {
+    mpeg1 := m.audioversion == 1
+    channels := m.channels()
+
+    w := &mp3bitwriter{ bytes: make([]byte, m.sideinfosize()) }
+
+    if mpeg1 {
+        w.write(9, s.maindatabegin)
+        if channels == 1 {
+            w.write(5, s.privatebits)
+        } else {
+            w.write(3, s.privatebits)
+        }
+        for ch := 0; ch < channels; ch++ {
+            for band := 0; band < 4; band++ {
+                w.writebool(s.scfsi[ch][band])
+            }
+        }
+    } else {
+        w.write(8, s.maindatabegin)
+        w.write(channels, s.privatebits)
+    }
+
+    for _, granule := range s.granules {
+        for _, g := range granule {
+            w.write(12, g.part23length)
+            w.write(9, g.bigvalues)
+            w.write(8, g.globalgain)
+            if mpeg1 {
+                w.write(4, g.scalefaccompress)
+            } else {
+                w.write(9, g.scalefaccompress)
+            }
+            w.writebool(g.windowswitching)
+
+            if g.windowswitching {
+                w.write(2, g.blocktype)
+                w.writebool(g.mixedblock)
+                for _, t := range g.tableselect {
+                    w.write(5, t)
+                }
+                for _, gain := range g.subblockgain {
+                    w.write(3, gain)
+                }
+            } else {
+                for _, t := range g.tableselect {
+                    w.write(5, t)
+                }
+                w.write(4, g.region0count)
+                w.write(3, g.region1count)
+            }
+
+            if mpeg1 {
+                w.writebool(g.preflag)
+            }
+            w.writebool(g.scalefacscale)
+            w.write(1, g.count1tableselect)
+        }
+    }
+
+    return w.bytes
+}
+
+
+// Silence the frame, bytes, in place so that it decodes without any earlier
+// frames, by not using the bit reservoir and giving every granule no main
+// data.  The main data is kept, as later frames may use it from the bit
+// reservoir.  The CRC, if protected, is updated.
+func (m *mp3header) silence(bytes []byte) (err error) {
+    if m.sideinfo == nil {
+        return fmt.Errorf("Unable to silence frame, no side information.")
+    }
+
+    m.sideinfo.maindatabegin = 0
+    for _, granule := range m.sideinfo.granules {
+        for _, g := range granule {
+            g.part23length = 0
+            g.bigvalues = 0
+            g.globalgain = 0
+        }
+    }
+    copy(bytes[m.sideinfooffset():m.maindataoffset()], m.sideinfo.bytes(m))
+
+    if m.protection {
+        if m.crc, err = m.computecrc(bytes); err != nil {
+            return err
+        }
+        binary.BigEndian.PutUint16(bytes[4:6], m.crc)
+    }
+
+    return nil
+}
@@ -0,0 +1,191 @@
+// 'mp3sideinfo_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+
+// Returns the bits, a string of '0' and '1' ignoring spaces, as bytes.
+func mp3sideinfobits(bits string) []byte {
+    bits = strings.Replace(bits, " ", "", -1)
+    bytes := make([]byte, (len(bits) + 7) / 8)
+    for j, bit := range bits {
+        if bit == '1' {
+            bytes[j / 8] |= 0x80 >> uint(j % 8)
+        }
+    }
+    return bytes
+}
+
+
+func Test_mp3sideinfompeg1(t *testing.T) {
+    // MPEG1 layer III, protected, 128kbps, 44100Hz, stereo.
+    frame := make([]byte, 417)
+    copy(frame, []byte{ 0xff, 0xfa, 0x90, 0x00, 0x12, 0x34 })
+
+    sideinfo := mp3sideinfobits("100101100 000 1010 0000" +
+                                // Granule 0, channel 0, short blocks.
+                                " 001111101000 011001000 10101010 1001" +
+                                " 1 10 0 00001 00010 001 010 011" +
+                                " 0 1 1" +
+                                // Granule 0, channel 1, long blocks.
+                                " 000000000001 000000010 00000001 0000" +
+                                " 0 00011 00100 00101 0110 101" +
+                                " 1 0 0")
+    copy(frame[6:], sideinfo)
+
+    m, err := newmp3headerfrombytes(frame)
+    if ! (err == nil &&
+          m.protection &&
+          m.crc == 0x1234 &&
+          m.sideinfo != nil &&
+          m.sideinfosize() == 32 &&
+          m.maindataoffset() == 38) {
+        t.Errorf("Test_mp3sideinfompeg1:  failed")
+        return
+    }
+
+    s := m.sideinfo
+    if ! (s.maindatabegin == 300 &&
+          s.privatebits == 0 &&
+          len(s.scfsi) == 2 &&
+          s.scfsi[0][0] && !s.scfsi[0][1] && s.scfsi[0][2] &&
+          len(s.granules) == 2 &&
+          len(s.granules[0]) == 2) {
+        t.Errorf("Test_mp3sideinfompeg1:  failed")
+        return
+    }
+
+    g := s.granules[0][0]
+    if ! (g.part23length == 1000 &&
+          g.bigvalues == 200 &&
+          g.globalgain == 170 &&
+          g.scalefaccompress == 9 &&
+          g.windowswitching &&
+          g.blocktypename() == "short" &&
+          !g.mixedblock &&
+          g.tableselect[0] == 1 && g.tableselect[1] == 2 &&
+          g.subblockgain[0] == 1 && g.subblockgain[2] == 3 &&
+          g.region0count == 8 &&
+          !g.preflag &&
+          g.scalefacscale &&
+          g.count1tableselect == 1) {
+        t.Errorf("Test_mp3sideinfompeg1:  failed")
+        return
+    }
+
+    g = s.granules[0][1]
+    if ! (g.part23length == 1 &&
+          g.bigvalues == 2 &&
+          g.globalgain == 1 &&
+          !g.windowswitching &&
+          g.blocktypename() == "normal" &&
+          g.tableselect[2] == 5 &&
+          g.region0count == 6 &&
+          g.region1count == 5 &&
+          g.preflag) {
+        t.Errorf("Test_mp3sideinfompeg1:  failed")
+        return
+    }
+
+    if ! (s.maindatasize() == 126) {
+        t.Errorf("Test_mp3sideinfompeg1:  failed")
+    }
+}
+
+
+func Test_mp3sideinfompeg2(t *testing.T) {
+    // MPEG2 layer III, unprotected, 64kbps, 22050Hz, mono.
+    frame := make([]byte, 208)
+    copy(frame, []byte{ 0xff, 0xf3, 0x80, 0xc0 })
+
+    sideinfo := mp3sideinfobits("11111111 1" +
+                                " 000000000100 000000011 00000010" +
+                                " 100000001 0" +
+                                " 00001 00010 00011 0001 001" +
+                                " 1 0")
+    copy(frame[4:], sideinfo)
+
+    m, err := newmp3headerfrombytes(frame)
+    if ! (err == nil &&
+          !m.protection &&
+          m.size == 208 &&
+          m.sideinfosize() == 9 &&
+          m.sideinfo != nil) {
+        t.Errorf("Test_mp3sideinfompeg2:  failed")
+        return
+    }
+
+    s := m.sideinfo
+    g := s.granules[0][0]
+    if ! (s.maindatabegin == 255 &&
+          s.privatebits == 1 &&
+          s.scfsi == nil &&
+          len(s.granules) == 1 &&
+          len(s.granules[0]) == 1 &&
+          g.part23length == 4 &&
+          g.scalefaccompress == 257 &&
+          g.region1count == 1 &&
+          g.scalefacscale) {
+        t.Errorf("Test_mp3sideinfompeg2:  failed")
+    }
+}
+
+
+// Silencing a frame zeroes the main data used and updates the CRC.
+func Test_mp3sideinfosilence(t *testing.T) {
+    frame := make([]byte, 417)
+    copy(frame, []byte{ 0xff, 0xfa, 0x90, 0x00 })
+    copy(frame[6:], mp3sideinfobits("100101100 000 1010 0000" +
+                                     " 001111101000 011001000 10101010"))
+    crc := mp3crc16(frame[2:4], frame[6:38])
+    frame[4] = byte(crc >> 8)
+    frame[5] = byte(crc)
+    frame[100] = 0x55
+
+    m, err := newmp3headerfrombytes(frame)
+    if ! (err == nil && m.silence(frame) == nil) {
+        t.Errorf("Test_mp3sideinfosilence:  failed")
+        return
+    }
+
+    m1, err := newmp3headerfrombytes(frame)
+    if ! (err == nil && m1.crc != crc && m1.crc == m.crc) {
+        t.Errorf("Test_mp3sideinfosilence:  failed")
+        return
+    }
+    if ok, _, err := m1.verifycrc(frame); ! (ok && err == nil) {
+        t.Errorf("Test_mp3sideinfosilence:  failed")
+        return
+    }
+
+    s := m1.sideinfo
+    g := s.granules[0][0]
+    if ! (s.maindatabegin == 0 &&
+          s.scfsi[0][0] &&
+          g.part23length == 0 &&
+          g.bigvalues == 0 &&
+          g.globalgain == 0 &&
+          frame[100] == 0x55) {
+        t.Errorf("Test_mp3sideinfosilence:  failed")
+        return
+    }
+
+    // Unprotected frames have no CRC to update.
+    frame = testmp3frame()
+    copy(frame[4:], mp3sideinfobits("100101100"))
+    m, _ = newmp3headerfrombytes(frame)
+    if ! (m.silence(frame) == nil &&
+          bytes.Equal(frame[0:4], testmp3frame()[0:4]) &&
+          frame[4] == 0) {
+        t.Errorf("Test_mp3sideinfosilence:  failed")
+        return
+    }
+}
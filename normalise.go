@@ -0,0 +1,33 @@
+// 'normalise.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "fmt"
+    "strings"
+
+    "golang.org/x/text/unicode/norm"
+)
+
+
+// See:  https://unicode.org/reports/tr15/
+// Text is normalised to NFC by default, as macOS filenames are NFD.
+var normalisationforms = map[string]norm.Form {
+    "nfc":  norm.NFC,
+    "nfd":  norm.NFD,
+    "nfkc": norm.NFKC,
+    "nfkd": norm.NFKD,
+}
+
+
+func findnormalisationform(name string) (f norm.Form, err error) {
+    f, ok := normalisationforms[strings.ToLower(name)]
+    if !ok {
+        return norm.NFC, fmt.Errorf("Unrecognised normalisation form %s",
+                                    name)
+    }
+    return f, nil
+}
@@ -0,0 +1,114 @@
+// 'rewritefile.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+)
+
+
+// Parse filename passing everything to the handler from newhandler, which
+// writes the new contents to '<filename>.new'.  This then replaces filename.
+// For a dry-run the new contents are discarded.
+func rewritefile(filename string,
+                 dryrun bool,
+                 newhandler func(out io.Writer) mp3adorahandler) (err error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    if dryrun {
+        mp3adora := newmp3adora(newhandler(ioutil.Discard))
+        _, err = mp3adora.parse(file)
+        return err
+    }
+
+    filenew, err := os.Create(fmt.Sprintf("%s.new", filename))
+    if err != nil {
+        return err
+    }
+    defer filenew.Close()
+
+    mp3adora := newmp3adora(newhandler(filenew))
+    if _, err = mp3adora.parse(file); err != nil {
+        os.Remove(filenew.Name())
+        return err
+    }
+
+    if err = filenew.Close(); err != nil {
+        os.Remove(filenew.Name())
+        return err
+    }
+
+    return os.Rename(filenew.Name(), filename)
+}
+
+
+// Replace the first size bytes of filename, i.e. its leading id3v2 tag, with
+// i by writing i and the rest of filename to '<filename>.new'.  This then
+// replaces filename.
+func replaceleadingid3v2(filename string, i *id3v2, size int) (err error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    if _, err = file.Seek(int64(size), io.SeekStart); err != nil {
+        return err
+    }
+
+    filenew, err := os.Create(fmt.Sprintf("%s.new", filename))
+    if err != nil {
+        return err
+    }
+    defer filenew.Close()
+
+    if _, err = filenew.Write(i.bytes()); err == nil {
+        _, err = io.Copy(filenew, file)
+    }
+    if err == nil {
+        err = filenew.Close()
+    }
+    if err != nil {
+        os.Remove(filenew.Name())
+        return err
+    }
+
+    return os.Rename(filenew.Name(), filename)
+}
+
+
+// Read len(bytes) bytes of filename at offset.
+func readat(filename string, bytes []byte, offset int) (err error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    _, err = file.ReadAt(bytes, int64(offset))
+    return err
+}
+
+
+// Parse filename passing everything to h.
+func parsefile(filename string, h mp3adorahandler) (err error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    mp3adora := newmp3adora(h)
+    _, err = mp3adora.parse(file)
+    return err
+}
@@ -0,0 +1,120 @@
+// 'transliterate.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "strings"
+    "unicode"
+
+    "golang.org/x/text/encoding"
+    "golang.org/x/text/unicode/norm"
+)
+
+
+// Ligatures, letters which do not decompose and typographic punctuation.
+var transliterationslatin = map[rune]string {
+    'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'ẞ': "SS", 'ß': "ss",
+    'Ĳ': "IJ", 'ĳ': "ij", 'ﬀ': "ff", 'ﬁ': "fi", 'ﬂ': "fl", 'ﬃ': "ffi",
+    'ﬄ': "ffl", 'ﬅ': "st", 'ﬆ': "st",
+    'Ø': "O", 'ø': "o", 'Đ': "D", 'đ': "d", 'Ł': "L", 'ł': "l",
+    'Þ': "Th", 'þ': "th", 'Ð': "D", 'ð': "d", 'Ħ': "H", 'ħ': "h",
+    'ı': "i", 'Ŀ': "L", 'ŀ': "l",
+    '‘': "'", '’': "'", '‚': "'", '“': "\"", '”': "\"", '„': "\"",
+    '–': "-", '—': "-", '…': "...", '«': "\"", '»': "\"",
+}
+
+
+// Russian, Ukrainian, Belarusian and Serbian.
+var transliterationscyrillic = map[rune]string {
+    'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Д': "D", 'Е': "E", 'Ё': "Yo",
+    'Ж': "Zh", 'З': "Z", 'И': "I", 'Й': "Y", 'К': "K", 'Л': "L", 'М': "M",
+    'Н': "N", 'О': "O", 'П': "P", 'Р': "R", 'С': "S", 'Т': "T", 'У': "U",
+    'Ф': "F", 'Х': "Kh", 'Ц': "Ts", 'Ч': "Ch", 'Ш': "Sh", 'Щ': "Shch",
+    'Ъ': "", 'Ы': "Y", 'Ь': "", 'Э': "E", 'Ю': "Yu", 'Я': "Ya",
+    'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+    'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+    'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+    'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+    'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+    'Є': "Ye", 'І': "I", 'Ї': "Yi", 'Ґ': "G", 'Ў': "U",
+    'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+    'Ђ': "Dj", 'Ј': "J", 'Љ': "Lj", 'Њ': "Nj", 'Ћ': "C", 'Џ': "Dz",
+    'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+}
+
+
+// Modern Greek, accents are removed by decomposition first.
+var transliterationsgreek = map[rune]string {
+    'Α': "A", 'Β': "V", 'Γ': "G", 'Δ': "D", 'Ε': "E", 'Ζ': "Z", 'Η': "I",
+    'Θ': "Th", 'Ι': "I", 'Κ': "K", 'Λ': "L", 'Μ': "M", 'Ν': "N", 'Ξ': "X",
+    'Ο': "O", 'Π': "P", 'Ρ': "R", 'Σ': "S", 'Τ': "T", 'Υ': "Y", 'Φ': "F",
+    'Χ': "Ch", 'Ψ': "Ps", 'Ω': "O",
+    'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+    'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+    'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+    'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+
+// Replace runes which e cannot encode with an approximation it can, leaving
+// any others for convert() to replace.
+func transliterate(e encoding.Encoding, s string) string {
+    encoder := e.NewEncoder()
+    encodable := func(s string) bool {
+        _, err := encoder.String(s)
+        return err == nil
+    }
+
+    var builder strings.Builder
+    for _, r := range s {
+        if r < 0x80 || encodable(string(r)) {
+            builder.WriteRune(r)
+            continue
+        }
+
+        if s1 := transliteraterune(e, r); s1 != string(r) && encodable(s1) {
+            builder.WriteString(s1)
+            continue
+        }
+
+        builder.WriteRune(r)
+    }
+    return builder.String()
+}
+
+
+// Try the tables, then decomposition with the diacritics stripped, which may
+// leave a rune e can encode, e.g. 'ü' from 'ǘ', or a rune in the tables, e.g.
+// 'α' from 'ά'.
+func transliteraterune(e encoding.Encoding, r rune) string {
+    for _, transliterations := range []map[rune]string{
+        transliterationslatin,
+        transliterationscyrillic,
+        transliterationsgreek,
+    } {
+        if s, ok := transliterations[r]; ok {
+            return s
+        }
+    }
+
+    decomposed := []rune(norm.NFD.String(string(r)))
+    if len(decomposed) == 1 {
+        return string(r)
+    }
+
+    // Note strip one diacritic at a time, keeping as many as e allows.
+    for len(decomposed) > 1 {
+        decomposed = decomposed[0:len(decomposed) - 1]
+        if unicode.Is(unicode.Mn, decomposed[len(decomposed) - 1]) {
+            s := norm.NFC.String(string(decomposed))
+            if _, err := e.NewEncoder().String(s); err == nil {
+                return s
+            }
+        }
+    }
+
+    return transliteraterune(e, decomposed[0])
+}
@@ -0,0 +1,274 @@
+// 'xing.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "encoding/binary"
+    "fmt"
+)
+
+
+// See:  http://gabriel.mp3-tech.org/mp3infotag.html
+// The Xing header is in a silent mp3 frame before the audio, just after the
+// side information, and gives the number of frames and bytes and a table of
+// contents for seeking.  Encoders use 'Xing' for VBR and 'Info' for CBR.  The
+// LAME extension, if any, follows.
+type xing struct {
+    name string
+    flags uint32
+    frames int
+    bytes int
+    toc []byte
+    quality int
+    lame []byte
+}
+
+
+const (
+    xingflagframes = 0x01
+    xingflagbytes = 0x02
+    xingflagtoc = 0x04
+    xingflagquality = 0x08
+)
+
+
+const xinglamesize = 36
+
+
+// Bytes are, after the side information:
+// 0..3:     'Xing' or 'Info'.
+// 4..7:     flags.
+// ..:       frames, if flagged, excluding this frame.
+// ..:       bytes, if flagged, including this frame.
+// ..:       table of contents, 100 bytes, if flagged.
+// ..:       quality, if flagged.
+// ..:       LAME extension, 36 bytes, if any.
+func newxingfromframe(bytes []byte) (x *xing, err error) {
+    m, err := newmp3headerfrombytes(bytes)
+    if err != nil {
+        return nil, err
+    }
+
+    offset := m.maindataoffset()
+    if m.layer != 3 || len(bytes) < offset + 8 {
+        return nil, fmt.Errorf("Unable to find xing header.")
+    }
+
+    name := string(bytes[offset:offset + 4])
+    if name != "Xing" && name != "Info" {
+        return nil, fmt.Errorf("Unable to find xing header.")
+    }
+
+    x = &xing{ name: name,
+               flags: binary.BigEndian.Uint32(bytes[offset + 4:offset + 8]) }
+    offset += 8
+
+    read := func(size int) []byte {
+        if offset + size > len(bytes) {
+            err = fmt.Errorf("Unable to parse xing header.")
+            return make([]byte, size)
+        }
+        offset += size
+        return bytes[offset - size:offset]
+    }
+
+    if x.flags & xingflagframes != 0 {
+        x.frames = int(binary.BigEndian.Uint32(read(4)))
+    }
+    if x.flags & xingflagbytes != 0 {
+        x.bytes = int(binary.BigEndian.Uint32(read(4)))
+    }
+    if x.flags & xingflagtoc != 0 {
+        x.toc = append([]byte{}, read(100)...)
+    }
+    if x.flags & xingflagquality != 0 {
+        x.quality = int(binary.BigEndian.Uint32(read(4)))
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    // Note the LAME extension begins with the encoder, e.g. 'LAME3.100'.
+    if offset + xinglamesize <= len(bytes) && bytes[offset] != 0 {
+        x.lame = append([]byte{}, bytes[offset:offset + xinglamesize]...)
+    }
+
+    return x, nil
+}
+
+
+// Returns a Xing header for the audio frames with headers, with a table of
+// contents assuming the frames are of equal duration, and the quality and
+// LAME extension of original, if any.  The bytes include the Xing frame
+// itself, of size size.  Note the quality is always given, as the LAME
+// extension is expected after it.
+func newxingfromheaders(headers []*mp3header,
+                        size int,
+                        original *xing) (x *xing) {
+    x = &xing{ name: "Info",
+               flags: xingflagframes | xingflagbytes | xingflagtoc |
+                      xingflagquality,
+               frames: len(headers),
+               bytes: size,
+               toc: make([]byte, 100) }
+
+    offsets := make([]int, len(headers))
+    for j, m := range headers {
+        if m.bitrate != headers[0].bitrate {
+            x.name = "Xing"
+        }
+        offsets[j] = x.bytes
+        x.bytes += m.size
+    }
+
+    for j := range x.toc {
+        if len(headers) == 0 {
+            break
+        }
+        position := offsets[j * len(headers) / 100] * 256 / x.bytes
+        if position > 255 {
+            position = 255
+        }
+        x.toc[j] = byte(position)
+    }
+
+    if original != nil {
+        x.quality = original.quality
+        if original.lame != nil {
+            x.lame = append([]byte{}, original.lame...)
+        }
+    }
+
+    return x
+}
+
+
+// Returns the silent frame holding a Xing header for the audio frames with
+// headers, like template, a 4 byte mp3 frame header, keeping the quality and
+// LAME extension of original, if any.
+func newxingframe(template []byte,
+                  headers []*mp3header,
+                  original *xing) (bytes []byte, err error) {
+    // Note the size of the Xing frame does not depend on the values.
+    size, err := newxingfromheaders(headers, 0, original).framesize(template)
+    if err != nil {
+        return nil, err
+    }
+    return newxingfromheaders(headers, size, original).frame(template)
+}
+
+
+func (x *xing) tagbytes() []byte {
+    bytes := make([]byte, 8)
+    copy(bytes[0:4], x.name)
+    binary.BigEndian.PutUint32(bytes[4:8], x.flags)
+
+    bytes4 := make([]byte, 4)
+    if x.flags & xingflagframes != 0 {
+        binary.BigEndian.PutUint32(bytes4, uint32(x.frames))
+        bytes = append(bytes, bytes4...)
+    }
+    if x.flags & xingflagbytes != 0 {
+        binary.BigEndian.PutUint32(bytes4, uint32(x.bytes))
+        bytes = append(bytes, bytes4...)
+    }
+    if x.flags & xingflagtoc != 0 {
+        bytes = append(bytes, x.toc...)
+    }
+    if x.flags & xingflagquality != 0 {
+        binary.BigEndian.PutUint32(bytes4, uint32(x.quality))
+        bytes = append(bytes, bytes4...)
+    }
+
+    return append(bytes, x.lame...)
+}
+
+
+// Returns the size of the silent frame holding the Xing header, for audio
+// frames like template, a 4 byte mp3 frame header.
+func (x *xing) framesize(template []byte) (size int, err error) {
+    header, err := x.frameheader(template)
+    if err != nil {
+        return 0, err
+    }
+    m, err := newmp3headerfrombytes(header)
+    if err != nil {
+        return 0, err
+    }
+    return m.size, nil
+}
+
+
+// Returns the mp3 frame header for the Xing frame, which is template without
+// CRC, padding or private bit, and with the bitrate of template raised until
+// the Xing header fits.  Note VBR too starts at the bitrate of template, not
+// the lowest bitrate, so that the size of the frame does not depend on the
+// name.  Then an existing Xing frame used as template keeps its size when
+// the audio frames change between CBR and VBR, and can be updated in place.
+func (x *xing) frameheader(template []byte) (header []byte, err error) {
+    header = append([]byte{}, template[0:4]...)
+    header[1] |= 0x01
+    header[2] &^= 0x03
+
+    for bitrate := int(header[2] >> 4); bitrate < 15; bitrate++ {
+        header[2] = header[2] & 0x0f | byte(bitrate << 4)
+        m, err := newmp3headerfrombytes(header)
+        if err != nil {
+            return nil, err
+        }
+        if m.size >= m.maindataoffset() + len(x.tagbytes()) {
+            return header, nil
+        }
+    }
+
+    return nil, fmt.Errorf("Unable to fit xing header in an mp3 frame.")
+}
+
+
+// Returns the silent frame holding the Xing header, for audio frames like
+// template.  The LAME extension's music length and CRC are updated.
+func (x *xing) frame(template []byte) (bytes []byte, err error) {
+    header, err := x.frameheader(template)
+    if err != nil {
+        return nil, err
+    }
+    m, err := newmp3headerfrombytes(header)
+    if err != nil {
+        return nil, err
+    }
+
+    bytes = make([]byte, m.size)
+    copy(bytes, header)
+    tag := x.tagbytes()
+    copy(bytes[m.maindataoffset():], tag)
+
+    if x.lame != nil {
+        lame := bytes[m.maindataoffset() + len(tag) - xinglamesize:]
+        binary.BigEndian.PutUint32(lame[28:32], uint32(x.bytes))
+        crc := xinglamecrc16(bytes[0:m.maindataoffset() + len(tag) - 2])
+        binary.BigEndian.PutUint16(lame[34:36], crc)
+    }
+
+    return bytes, nil
+}
+
+
+// The LAME extension's CRCs are CRC-16 with the reflected polynomial 0xa001
+// and initial value 0.
+func xinglamecrc16(bytes []byte) uint16 {
+    crc := uint16(0)
+    for _, b := range bytes {
+        crc ^= uint16(b)
+        for j := 0; j < 8; j++ {
+            if crc & 0x0001 != 0 {
+                crc = crc >> 1 ^ 0xa001
+            } else {
+                crc >>= 1
+            }
+        }
+    }
+    return crc
+}
@@ -0,0 +1,105 @@
+// 'xing_test.go'.
+// Chris Shiels.
+
+
+package main
+
+
+import (
+    "testing"
+)
+
+
+func Test_xing(t *testing.T) {
+    template := []byte{ 0xff, 0xfb, 0x90, 0x00 }
+    m, _ := newmp3headerfrombytes(template)
+    m1, _ := newmp3headerfrombytes([]byte{ 0xff, 0xfb, 0xb0, 0x00 })
+
+    headers := []*mp3header{}
+    for j := 0; j < 100; j++ {
+        headers = append(headers, m)
+    }
+
+    bytes, err := newxingframe(template, headers, nil)
+    if err != nil {
+        t.Errorf("Test_xing:  failed")
+        return
+    }
+    x, err := newxingfromframe(bytes)
+    if ! (err == nil &&
+          x.name == "Info" &&
+          x.frames == 100 &&
+          x.bytes == len(bytes) + 100 * 417 &&
+          len(x.toc) == 100 &&
+          x.toc[0] == 2 &&
+          x.lame == nil) {
+        t.Errorf("Test_xing:  failed")
+        return
+    }
+
+    headers[50] = m1
+    original := &xing{ quality: 78, lame: make([]byte, xinglamesize) }
+    copy(original.lame, "LAME3.100")
+
+    bytes, err = newxingframe(template, headers, original)
+    if err != nil {
+        t.Errorf("Test_xing:  failed")
+        return
+    }
+    x, err = newxingfromframe(bytes)
+    if ! (err == nil &&
+          x.name == "Xing" &&
+          x.quality == 78 &&
+          x.bytes == len(bytes) + 99 * 417 + 626 &&
+          string(x.lame[0:9]) == "LAME3.100" &&
+          int(x.lame[31]) == x.bytes & 0xff &&
+          xinglamecrc16(bytes[0:190]) ==
+              uint16(x.lame[34]) << 8 | uint16(x.lame[35])) {
+        t.Errorf("Test_xing:  failed")
+    }
+}
+
+
+// The Xing frame has the bitrate of template, raised only if the Xing header
+// does not fit, for VBR as for CBR.
+func Test_xingframeheader(t *testing.T) {
+    m, _ := newmp3headerfrombytes([]byte{ 0xff, 0xfb, 0x90, 0x00 })
+    m1, _ := newmp3headerfrombytes([]byte{ 0xff, 0xfb, 0xb0, 0x00 })
+    cbr := []*mp3header{ m, m, m }
+    vbr := []*mp3header{ m, m1, m }
+    original := &xing{ lame: make([]byte, xinglamesize) }
+    copy(original.lame, "LAME3.100")
+
+    tests := []struct {
+        template []byte
+        headers []*mp3header
+        original *xing
+        size int
+    }{
+        { []byte{ 0xff, 0xfb, 0x90, 0x00 }, cbr, nil, 417 },
+        { []byte{ 0xff, 0xfb, 0x90, 0x00 }, vbr, nil, 417 },
+        // 32 kbps is too small, 48 kbps fits the Xing header and 64 kbps
+        // the LAME extension too.
+        { []byte{ 0xff, 0xfb, 0x10, 0x00 }, vbr, nil, 156 },
+        { []byte{ 0xff, 0xfb, 0x10, 0x00 }, vbr, original, 208 },
+        { []byte{ 0xff, 0xfb, 0x10, 0x00 }, cbr, original, 208 },
+    }
+    for _, test := range tests {
+        bytes, err := newxingframe(test.template, test.headers, test.original)
+        if ! (err == nil && len(bytes) == test.size) {
+            t.Errorf("Test_xingframeheader:  failed, %d", len(bytes))
+            return
+        }
+    }
+
+    // An Info frame used as template keeps its size as a Xing frame.
+    bytes, _ := newxingframe([]byte{ 0xff, 0xfb, 0x90, 0x00 }, cbr, nil)
+    bytes1, err := newxingframe(bytes[0:4], vbr, nil)
+    x, err1 := newxingfromframe(bytes1)
+    if ! (err == nil && err1 == nil &&
+          x.name == "Xing" &&
+          len(bytes1) == len(bytes)) {
+        t.Errorf("Test_xingframeheader:  failed")
+        return
+    }
+}